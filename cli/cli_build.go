@@ -8,6 +8,10 @@ var Build struct {
 		Force            bool   `alt:"f" desc:"Force build even if the project was cached"`
 		DeleteUnusedLibs bool   `desc:"Delete unused automatic libraries rather than appending .disabled to file names"`
 		ForceStringify   bool   `desc:"Forces variables in templates to be inserted even if they are of an unsupported type"`
+		KeepMeta         bool   `desc:"Keep the Vintage 'meta' object in exported pack.mcmeta files"`
+		Target           string `alt:"t" desc:"Comma-separated list of Minecraft versions to build for. Overrides 'meta.targets'"`
+		Reproducible     bool   `desc:"Create byte-identical .zip files from identical inputs (fixed timestamps & permissions)"`
+		CompressionLevel int    `desc:"Compression level of .zip files, from 1 (fastest) to 9 (best), or -1 to store files without compression" default:"6"`
 	}
 	Args struct {
 		WorkDir *string
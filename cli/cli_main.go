@@ -13,8 +13,6 @@ var Main struct {
 	Args struct{}
 }
 
-var UsesPluralFolderNames bool
-
 func ApplyWorkDir(work_dir *string) error {
 	if work_dir != nil {
 		return liberrors.NewIO(os.Chdir(*work_dir), *work_dir)
@@ -0,0 +1,20 @@
+package cli
+
+var Watch struct {
+	Options struct {
+		Output   string `alt:"o" desc:"Output directory relative to the pack working dir" default:"./build"`
+		Zip      bool   `alt:"z" desc:"Export data & resource packs as .zip files after every rebuild"`
+		Debug    bool   `alt:"d" desc:"Print verbose debug information"`
+		Interval int    `alt:"i" desc:"How often to check for changes (in milliseconds)" default:"250"`
+
+		DeleteUnusedLibs bool   `desc:"Delete unused automatic libraries rather than appending .disabled to file names"`
+		ForceStringify   bool   `desc:"Forces variables in templates to be inserted even if they are of an unsupported type"`
+		KeepMeta         bool   `desc:"Keep the Vintage 'meta' object in exported pack.mcmeta files"`
+		Target           string `alt:"t" desc:"Comma-separated list of Minecraft versions to build for. Overrides 'meta.targets'"`
+		Reproducible     bool   `desc:"Create byte-identical .zip files from identical inputs (fixed timestamps & permissions)"`
+		CompressionLevel int    `desc:"Compression level of .zip files, from 1 (fastest) to 9 (best), or -1 to store files without compression" default:"6"`
+	}
+	Args struct {
+		WorkDir *string
+	}
+}
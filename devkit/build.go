@@ -1,21 +1,16 @@
 package devkit
 
 import (
+	"context"
 	"os"
-	"time"
+	"os/signal"
+	"strings"
 
-	liberrors "github.com/bbfh-dev/lib-errors"
 	liblog "github.com/bbfh-dev/lib-log"
 	"github.com/bbfh-dev/vintage/cli"
-	"github.com/bbfh-dev/vintage/devkit/internal/drive"
-	"github.com/bbfh-dev/vintage/devkit/minecraft"
 )
 
 func Build(raw_args []string) error {
-	if err := cli.ApplyWorkDir(cli.Build.Args.WorkDir); err != nil {
-		return err
-	}
-
 	// Sync DEBUG
 	if cli.Main.Options.Debug || cli.Build.Options.Debug {
 		cli.Main.Options.Debug = true
@@ -23,29 +18,40 @@ func Build(raw_args []string) error {
 		liblog.LogLevel = liblog.LEVEL_DEBUG
 	}
 
-	mcmeta_body, err := os.ReadFile("pack.mcmeta")
-	if err != nil {
-		work_dir, _ := os.Getwd()
-		return liberrors.NewIO(err, work_dir)
+	options := Options{
+		Output:           cli.Build.Options.Output,
+		Zip:              cli.Build.Options.Zip,
+		Force:            cli.Build.Options.Force,
+		DeleteUnusedLibs: cli.Build.Options.DeleteUnusedLibs,
+		ForceStringify:   cli.Build.Options.ForceStringify,
+		KeepMeta:         cli.Build.Options.KeepMeta,
+		Targets:          splitTargets(cli.Build.Options.Target),
+		Reproducible:     cli.Build.Options.Reproducible,
+		CompressionLevel: cli.Build.Options.CompressionLevel,
 	}
 
-	mcmeta := minecraft.NewPackMcmeta(mcmeta_body)
-	if err := mcmeta.Validate(); err != nil {
-		return &liberrors.DetailedError{
-			Label:   liberrors.ERR_VALIDATE,
-			Context: liberrors.DirContext{Path: drive.ToAbs("pack.mcmeta")},
-			Details: err.Error(),
-		}
-	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	return NewBuilder(options).Build(ctx, workDirOf(cli.Build.Args.WorkDir))
+}
 
-	cli.UsesPluralFolderNames = minecraft.UsesPluralFolderNames(mcmeta.Minecraft()[0])
+// Splits the value of '--target'
+func splitTargets(value string) []string {
+	if value == "" {
+		return nil
+	}
 
-	start := time.Now()
-	project := New(mcmeta)
-	if err := project.Build(); err != nil {
-		return err
+	targets := []string{}
+	for version := range strings.SplitSeq(value, ",") {
+		targets = append(targets, strings.TrimSpace(version))
 	}
+	return targets
+}
 
-	liblog.Done(0, "Finished building in %s", time.Since(start))
-	return nil
+func workDirOf(work_dir *string) string {
+	if work_dir == nil {
+		return "."
+	}
+	return *work_dir
 }
@@ -0,0 +1,184 @@
+package devkit
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	liberrors "github.com/bbfh-dev/lib-errors"
+	liblog "github.com/bbfh-dev/lib-log"
+	"github.com/bbfh-dev/vintage/devkit/internal/drive"
+	"github.com/bbfh-dev/vintage/devkit/minecraft"
+	"github.com/bbfh-dev/vintage/devkit/vfs"
+)
+
+// Options that affect how a project is built.
+// The zero value is valid, except that [Options.Output] or [Options.Sink] is required.
+type Options struct {
+	// Build directory. Relative paths are relative to the project dir
+	Output string
+	// Where the build is written instead of [Options.Output]
+	Sink vfs.Sink
+	// Export data & resource packs as .zip files
+	Zip bool
+	// Build even if the project was cached
+	Force bool
+	// Delete unused automatic libraries rather than appending .disabled to file names
+	DeleteUnusedLibs bool
+	// Insert variables in templates even if they are of an unsupported type
+	ForceStringify bool
+	// Keep the Vintage 'meta' object in exported pack.mcmeta files
+	KeepMeta bool
+	// Minecraft versions to build for. Overrides 'meta.targets'
+	Targets []string
+	// Create byte-identical .zip files from identical inputs
+	Reproducible bool
+	// Compression level of .zip files, from 1 (fastest) to 9 (best) or [NO_COMPRESSION].
+	// 0 means [DEFAULT_COMPRESSION_LEVEL]
+	CompressionLevel int
+}
+
+// Compression level of .zip files when [Options.CompressionLevel] is not set
+const DEFAULT_COMPRESSION_LEVEL = drive.DEFAULT_COMPRESSION_LEVEL
+
+// Stores files in .zip files without compressing them
+const NO_COMPRESSION = drive.NO_COMPRESSION
+
+func (options Options) Validate() error {
+	if options.Output == "" && options.Sink == nil {
+		return &liberrors.DetailedError{
+			Label:   liberrors.ERR_VALIDATE,
+			Context: liberrors.DirContext{Path: "--output"},
+			Details: "expected a build directory",
+		}
+	}
+	if level := options.CompressionLevel; level < NO_COMPRESSION || level > 9 {
+		return &liberrors.DetailedError{
+			Label:   liberrors.ERR_VALIDATE,
+			Context: liberrors.DirContext{Path: "--compression-level"},
+			Details: fmt.Sprintf("expected a level from 1 to 9, 0 (default) or -1 (store), but got %d", level),
+		}
+	}
+	for _, version := range options.Targets {
+		if err := minecraft.ValidateTarget([2]string{version}); err != nil {
+			return &liberrors.DetailedError{
+				Label:   liberrors.ERR_VALIDATE,
+				Context: liberrors.DirContext{Path: "--target"},
+				Details: "target " + err.Error(),
+			}
+		}
+	}
+	return nil
+}
+
+// Builds projects with the same options.
+// A builder holds no state, so it can run any number of builds concurrently.
+type Builder struct {
+	Options Options
+}
+
+func NewBuilder(options Options) *Builder {
+	return &Builder{Options: options}
+}
+
+// Builds the project in [dir] for every target.
+// Stops between stages and returns the error of [ctx] once it is done.
+func (builder *Builder) Build(ctx context.Context, dir string) error {
+	output := builder.Options.Sink
+	if output == nil {
+		output = newOutputSink(dir, builder.Options.Output)
+	}
+
+	return builder.build(ctx, os.DirFS(dir), dir, output)
+}
+
+// Returns a sink that writes into [output], which is relative to [dir] unless absolute
+func newOutputSink(dir, output string) *vfs.DirSink {
+	if !filepath.IsAbs(output) {
+		output = filepath.Join(dir, output)
+	}
+	return vfs.NewDirSink(output)
+}
+
+// Same as [Builder.Build], but reads the project from [fsys].
+// Programs of templates are extracted into a temporary directory to run them,
+// and automatic libraries are not managed since they require the project to be on disk.
+func (builder *Builder) BuildFS(ctx context.Context, fsys fs.FS) error {
+	output := builder.Options.Sink
+	if output == nil {
+		output = vfs.NewDirSink(builder.Options.Output)
+	}
+
+	return builder.build(ctx, fsys, "", output)
+}
+
+func (builder *Builder) build(ctx context.Context, fsys fs.FS, dir string, output vfs.Sink) error {
+	if err := builder.Options.Validate(); err != nil {
+		return err
+	}
+
+	mcmeta, err := loadPackMcmeta(fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	targets := mcmeta.Targets()
+	if len(builder.Options.Targets) != 0 {
+		targets = [][2]string{}
+		for _, version := range builder.Options.Targets {
+			targets = append(targets, [2]string{version})
+		}
+	}
+
+	start := time.Now()
+	if len(targets) == 0 {
+		project := New(fsys, output, mcmeta, builder.Options)
+		project.Dir = dir
+		if err := project.Build(ctx); err != nil {
+			return err
+		}
+
+		liblog.Done(0, "Finished building in %s", time.Since(start))
+		return nil
+	}
+
+	for _, target := range targets {
+		target_output := vfs.Sub(output, minecraft.TargetName(target))
+		project := New(fsys, target_output, mcmeta.WithTarget(target), builder.Options)
+		project.Dir = dir
+		if err := project.Build(ctx); err != nil {
+			return err
+		}
+	}
+
+	liblog.Done(0, "Finished building %d target(s) in %s", len(targets), time.Since(start))
+	return nil
+}
+
+// Reads and validates pack.mcmeta of the project in [fsys] located at [dir] (if it is on disk)
+func loadPackMcmeta(fsys fs.FS, dir string) (*minecraft.PackMcmeta, error) {
+	path := filepath.Join(dir, "pack.mcmeta")
+	mcmeta_body, err := fs.ReadFile(fsys, "pack.mcmeta")
+	if err != nil {
+		return nil, liberrors.NewIO(err, path)
+	}
+
+	mcmeta := minecraft.NewPackMcmeta(mcmeta_body)
+	if err := mcmeta.Validate(); err != nil {
+		return nil, &liberrors.DetailedError{
+			Label:   liberrors.ERR_VALIDATE,
+			Context: liberrors.DirContext{Path: path},
+			Details: err.Error(),
+		}
+	}
+
+	return mcmeta, nil
+}
+
+func getFolderLayout(mcmeta *minecraft.PackMcmeta) minecraft.FolderLayout {
+	versions := mcmeta.Clone().FillVersion("data", minecraft.DataPackFormats).Versions
+	return minecraft.FolderLayoutOf(versions)
+}
@@ -10,7 +10,6 @@ import (
 
 	liberrors "github.com/bbfh-dev/lib-errors"
 	liblog "github.com/bbfh-dev/lib-log"
-	"github.com/bbfh-dev/vintage/cli"
 	"github.com/bbfh-dev/vintage/devkit/internal/code"
 	"github.com/bbfh-dev/vintage/devkit/internal/drive"
 	"github.com/schollz/progressbar/v3"
@@ -21,6 +20,8 @@ type Library struct {
 	Namespaces []string
 	Path       string
 	File       *drive.JsonFile
+	// Whether unused libraries are deleted rather than disabled
+	DeleteUnused bool
 }
 
 func (lib *Library) IsUsed() bool {
@@ -169,7 +170,7 @@ func (lib *Library) Manage() error {
 		}
 		path := filepath.Join(lib.Dir(), installed.String())
 
-		if cli.Build.Options.DeleteUnusedLibs {
+		if lib.DeleteUnused {
 			err := os.Remove(path)
 			if err != nil {
 				return liberrors.NewIO(err, path)
@@ -0,0 +1,187 @@
+// Content-hash based build cache that is persisted in the build directory
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	liberrors "github.com/bbfh-dev/lib-errors"
+	"github.com/bbfh-dev/vintage/devkit/vfs"
+)
+
+const FILENAME = ".vintage_cache.json"
+
+// Bump whenever the format of [Manifest] changes, so that old caches are ignored
+const VERSION = 3
+
+type Manifest struct {
+	Version int               `json:"version"`
+	Packs   map[string]*Entry `json:"packs"`
+}
+
+// Describes all inputs that were used to build a single pack
+type Entry struct {
+	Digest  string            `json:"digest"`
+	Options map[string]string `json:"options"`
+	Files   map[string]string `json:"files"`
+	// Source file → files it emitted, see [Graph]
+	Outputs map[string][]string `json:"outputs,omitempty"`
+	// Source file → namespaces used by the functions it emitted
+	Namespaces map[string][]string `json:"namespaces,omitempty"`
+}
+
+func New() *Manifest {
+	return &Manifest{
+		Version: VERSION,
+		Packs:   map[string]*Entry{},
+	}
+}
+
+// Reads the manifest from [name] inside of [fsys].
+// Returns an empty manifest if it's missing, corrupted or outdated.
+func Load(fsys fs.FS, name string) *Manifest {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return New()
+	}
+
+	manifest := New()
+	if err := json.Unmarshal(data, manifest); err != nil || manifest.Version != VERSION {
+		return New()
+	}
+	if manifest.Packs == nil {
+		manifest.Packs = map[string]*Entry{}
+	}
+
+	return manifest
+}
+
+func (manifest *Manifest) Save(sink vfs.Sink, name string) error {
+	data, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return liberrors.NewIO(err, name)
+	}
+
+	return liberrors.NewIO(sink.WriteFile(name, data), name)
+}
+
+// Hashes every file inside of the provided paths (relative to [fsys]). Missing paths are ignored.
+func NewEntry(fsys fs.FS, paths []string, options map[string]string) (*Entry, error) {
+	entry := &Entry{
+		Options: options,
+		Files:   map[string]string{},
+	}
+
+	for _, path := range paths {
+		err := fs.WalkDir(fsys, filepath.ToSlash(path), func(path string, dir_entry fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			if err != nil || dir_entry.IsDir() {
+				return err
+			}
+
+			hash, err := HashFile(fsys, path)
+			if err != nil {
+				return err
+			}
+			entry.Files[path] = hash
+			return nil
+		})
+		if err != nil {
+			return nil, liberrors.NewIO(err, path)
+		}
+	}
+
+	entry.Digest = entry.computeDigest()
+	return entry, nil
+}
+
+// Returns sorted paths of files that differ in [entry] compared to [previous] and
+// are inside of [dirs], as well as those that no longer exist.
+func (entry *Entry) ChangedIn(previous *Entry, dirs ...string) (changed []string, removed []string) {
+	for path, hash := range entry.Files {
+		if isInside(path, dirs) && previous.Files[path] != hash {
+			changed = append(changed, path)
+		}
+	}
+	for path := range previous.Files {
+		if _, ok := entry.Files[path]; !ok && isInside(path, dirs) {
+			removed = append(removed, path)
+		}
+	}
+
+	slices.Sort(changed)
+	slices.Sort(removed)
+	return changed, removed
+}
+
+// Whether the options or any file outside of [dirs] differ compared to [previous]
+func (entry *Entry) ChangedOutside(previous *Entry, dirs ...string) bool {
+	if !maps.Equal(entry.Options, previous.Options) {
+		return true
+	}
+
+	for path, hash := range entry.Files {
+		if !isInside(path, dirs) && previous.Files[path] != hash {
+			return true
+		}
+	}
+	for path := range previous.Files {
+		if _, ok := entry.Files[path]; !ok && !isInside(path, dirs) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isInside(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if strings.HasPrefix(path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether both entries were built from identical inputs
+func (entry *Entry) Matches(other *Entry) bool {
+	return entry != nil && other != nil && entry.Digest == other.Digest
+}
+
+func (entry *Entry) computeDigest() string {
+	hash := sha256.New()
+
+	for _, key := range slices.Sorted(maps.Keys(entry.Options)) {
+		io.WriteString(hash, "option\x00"+key+"\x00"+entry.Options[key]+"\n")
+	}
+	for _, path := range slices.Sorted(maps.Keys(entry.Files)) {
+		io.WriteString(hash, "file\x00"+path+"\x00"+entry.Files[path]+"\n")
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func HashFile(fsys fs.FS, path string) (string, error) {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
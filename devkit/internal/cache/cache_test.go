@@ -0,0 +1,56 @@
+package cache_test
+
+import (
+	"testing"
+
+	"github.com/bbfh-dev/vintage/devkit/internal/cache"
+	"gotest.tools/assert"
+)
+
+func TestEntryChanges(t *testing.T) {
+	previous := &cache.Entry{
+		Options: map[string]string{"zip": "false"},
+		Files: map[string]string{
+			"pack.mcmeta":                   "a",
+			"data/ns/function/a.mcfunction": "b",
+			"data/ns/function/b.mcfunction": "c",
+		},
+	}
+	current := &cache.Entry{
+		Options: map[string]string{"zip": "false"},
+		Files: map[string]string{
+			"pack.mcmeta":                   "a",
+			"data/ns/function/a.mcfunction": "changed",
+			"data/ns/function/c.mcfunction": "d",
+		},
+	}
+
+	changed, removed := current.ChangedIn(previous, "data")
+	assert.DeepEqual(t, changed, []string{"data/ns/function/a.mcfunction", "data/ns/function/c.mcfunction"})
+	assert.DeepEqual(t, removed, []string{"data/ns/function/b.mcfunction"})
+	assert.Assert(t, !current.ChangedOutside(previous, "data"))
+
+	current.Options["zip"] = "true"
+	assert.Assert(t, current.ChangedOutside(previous, "data"))
+}
+
+func TestGraph(t *testing.T) {
+	graph := cache.NewGraph(nil)
+	graph.Add("data/ns/function/a.mcfunction", "data/ns/function/shared.mcfunction")
+	graph.Add("data/ns/function/b.mcfunction", "data/ns/function/shared.mcfunction")
+	graph.Add("data/ns/function/b.mcfunction", "data/ns/function/shared.mcfunction")
+
+	assert.DeepEqual(
+		t,
+		graph.SourcesOf("data/ns/function/shared.mcfunction"),
+		[]string{"data/ns/function/a.mcfunction", "data/ns/function/b.mcfunction"},
+	)
+	assert.DeepEqual(
+		t,
+		graph.Remove("data/ns/function/b.mcfunction"),
+		[]string{"data/ns/function/shared.mcfunction"},
+	)
+	assert.DeepEqual(t, graph.Export(), map[string][]string{
+		"data/ns/function/a.mcfunction": {"data/ns/function/shared.mcfunction"},
+	})
+}
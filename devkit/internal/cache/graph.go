@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Maps source files to the output files (relative to the pack root) they emit.
+//
+// Safe for concurrent use.
+type Graph struct {
+	mutex   sync.Mutex
+	outputs map[string][]string
+}
+
+func NewGraph(outputs map[string][]string) *Graph {
+	graph := &Graph{outputs: make(map[string][]string, len(outputs))}
+	for source, paths := range outputs {
+		graph.outputs[source] = slices.Clone(paths)
+	}
+	return graph
+}
+
+func (graph *Graph) Add(source, output string) {
+	graph.mutex.Lock()
+	defer graph.mutex.Unlock()
+
+	if !slices.Contains(graph.outputs[source], output) {
+		graph.outputs[source] = append(graph.outputs[source], output)
+	}
+}
+
+// Forgets about the source and returns the outputs it used to emit
+func (graph *Graph) Remove(source string) []string {
+	graph.mutex.Lock()
+	defer graph.mutex.Unlock()
+
+	outputs := graph.outputs[source]
+	delete(graph.outputs, source)
+	return outputs
+}
+
+func (graph *Graph) OutputsOf(source string) []string {
+	graph.mutex.Lock()
+	defer graph.mutex.Unlock()
+
+	return slices.Clone(graph.outputs[source])
+}
+
+// Returns every source that emits [output]
+func (graph *Graph) SourcesOf(output string) []string {
+	graph.mutex.Lock()
+	defer graph.mutex.Unlock()
+
+	sources := []string{}
+	for source, paths := range graph.outputs {
+		if slices.Contains(paths, output) {
+			sources = append(sources, source)
+		}
+	}
+	slices.Sort(sources)
+	return sources
+}
+
+// Returns a sorted copy that is suitable for [Entry.Outputs]
+func (graph *Graph) Export() map[string][]string {
+	graph.mutex.Lock()
+	defer graph.mutex.Unlock()
+
+	out := make(map[string][]string, len(graph.outputs))
+	for _, source := range slices.Sorted(maps.Keys(graph.outputs)) {
+		out[source] = slices.Sorted(slices.Values(graph.outputs[source]))
+	}
+	return out
+}
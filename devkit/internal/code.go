@@ -33,6 +33,18 @@ func PathToResource(path string) string {
 	}
 }
 
+// Returns the part of the path that precedes the "data" or "assets" folder.
+// It is empty for regular sources and "overlays/<name>" for overlays.
+func PathPrefix(path string) string {
+	fields := strings.Split(filepath.ToSlash(path), "/")
+	for i, field := range fields {
+		if field == "data" || field == "assets" {
+			return filepath.Join(fields[:i]...)
+		}
+	}
+	return ""
+}
+
 func ResourceToPath(folder_name, resource string) string {
 	parts := strings.SplitN(resource, ":", 2)
 	if len(parts) == 1 {
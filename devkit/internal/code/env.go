@@ -8,6 +8,8 @@ type Rows []Columns
 type Env struct {
 	Iterators map[string]Columns
 	Variables map[string]Variable
+	// Insert JSON values minified instead of rejecting them in simple substitution
+	ForceStringify bool
 }
 
 func NewEnv() Env {
@@ -3,6 +3,7 @@ package code
 import (
 	"bufio"
 	"strings"
+	"unicode"
 )
 
 func ExtractVariablesFrom(in string) []string {
@@ -92,3 +93,18 @@ func ExtractResourceFrom(line string) string {
 	}
 	return ""
 }
+
+// Splits a "key=value" argument, where key is made of letters, digits and '_'.
+// Reports false for any other argument, e.g. "@e[tag=a]".
+func CutNamedArg(arg string) (key, value string, ok bool) {
+	key, value, ok = strings.Cut(arg, "=")
+	if !ok || key == "" {
+		return "", "", false
+	}
+	for _, char := range key {
+		if char != '_' && !unicode.IsLetter(char) && !unicode.IsDigit(char) {
+			return "", "", false
+		}
+	}
+	return key, value, true
+}
@@ -6,7 +6,6 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/bbfh-dev/vintage/cli"
 	"github.com/bbfh-dev/vintage/devkit/internal/drive"
 	"github.com/tidwall/gjson"
 )
@@ -80,7 +79,7 @@ func SubstituteString(in string, env Env) (string, error) {
 			value = Query(value, suffix)
 		}
 		if !IsStringifiable(value) {
-			if cli.Build.Options.ForceStringify {
+			if env.ForceStringify {
 				out := value.String()
 				out = strings.ReplaceAll(out, "\t", "")
 				out = strings.ReplaceAll(out, " ", "")
@@ -0,0 +1,32 @@
+package drive_test
+
+import (
+	"testing"
+
+	"github.com/bbfh-dev/vintage/devkit/internal/drive"
+	"gotest.tools/assert"
+)
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern, name string
+		expect        bool
+	}{
+		{"**/*.mcfunction", "data/example/function/test.mcfunction", true},
+		{"**/*.mcfunction", "test.mcfunction", true},
+		{"**/*.mcfunction", "data/example/loot_table/test.json", false},
+		{"data/*/function/**", "data/example/function/a/b.mcfunction", true},
+		{"data/*/function/**", "data/example/loot_table/a.json", false},
+		{"assets/**/lang/*.json", "assets/minecraft/lang/en_us.json", true},
+		{"assets/**/lang/*.json", "assets/lang/en_us.json", true},
+		{"pack.mcmeta", "pack.mcmeta", true},
+		{"pack.mcmeta", "data/pack.mcmeta", false},
+	}
+
+	for _, test := range cases {
+		assert.Equal(t, drive.MatchGlob(test.pattern, test.name), test.expect, "%s ~ %s", test.pattern, test.name)
+	}
+
+	assert.NilError(t, drive.ValidateGlob("**/[a-z]*.json"))
+	assert.Assert(t, drive.ValidateGlob("data/[a-") != nil)
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"slices"
 
-	liblog "github.com/bbfh-dev/lib-log"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/pretty"
 	"github.com/tidwall/sjson"
@@ -72,6 +71,19 @@ func (file *JsonFile) Set(path string, value any) {
 	}
 }
 
+func (file *JsonFile) SetRaw(path string, raw string) {
+	var err error
+	file.Body, err = sjson.SetRawBytes(file.Body, path, []byte(raw))
+	if err != nil {
+		panic(
+			fmt.Sprintf(
+				"(Assertion fail) Failed setting inside of the json file: %s",
+				err.Error(),
+			),
+		)
+	}
+}
+
 func (file *JsonFile) Delete(path string) {
 	var err error
 	file.Body, err = sjson.DeleteBytes(file.Body, path)
@@ -95,38 +107,3 @@ var formattingOptions = &pretty.Options{
 func (file *JsonFile) Formatted() []byte {
 	return pretty.PrettyOptions(file.Body, formattingOptions)
 }
-
-// Merges top-level JSON keys.
-// Overrides the value with [target] if merging isn't possible (string, number, etc.).
-func (file *JsonFile) MergeWith(target *JsonFile) {
-	for _, key := range target.Get("@keys").Array() {
-		key := key.String()
-		origin_value := file.Get(key)
-		target_value := target.Get(key)
-
-		if !origin_value.Exists() {
-			file.Set(key, target_value.Value())
-			continue
-		}
-
-		if origin_value.IsArray() {
-			items := make([]any, 0, len(origin_value.Array())+len(target_value.Array()))
-			for _, item := range origin_value.Array() {
-				items = append(items, item.Value())
-			}
-			for _, item := range target_value.Array() {
-				items = append(items, item.Value())
-			}
-
-			file.Set(key, items)
-			continue
-		}
-
-		if origin_value.IsObject() {
-			liblog.Debug(2, "WARN: Recursive merging is not implemented, overriding %q", key)
-			continue
-		}
-
-		file.Set(key, target_value.Value())
-	}
-}
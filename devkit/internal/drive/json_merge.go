@@ -0,0 +1,224 @@
+package drive
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/pretty"
+)
+
+type MergeStrategy string
+
+const (
+	// Objects are merged recursively, arrays follow [MergeRules.Arrays], other values are overridden
+	MERGE_DEEP MergeStrategy = "merge"
+	// Arrays are concatenated
+	MERGE_APPEND MergeStrategy = "append"
+	// Arrays are concatenated without duplicates
+	MERGE_UNION MergeStrategy = "union"
+	// The new value replaces the original one
+	MERGE_OVERRIDE MergeStrategy = "override"
+	// The original value is kept
+	MERGE_KEEP MergeStrategy = "keep"
+)
+
+var MergeStrategies = []MergeStrategy{
+	MERGE_DEEP,
+	MERGE_APPEND,
+	MERGE_UNION,
+	MERGE_OVERRIDE,
+	MERGE_KEEP,
+}
+
+type MergeRules struct {
+	// Strategy used for arrays by [MERGE_DEEP]
+	Arrays MergeStrategy
+	// Dot-separated JSON path → strategy. A '*' segment matches any key
+	Paths map[string]MergeStrategy
+	// Whether a top-level 'replace: true' (as in tags) replaces the whole file
+	HonorReplace bool
+	// Called whenever a value is overridden by a different one
+	OnConflict func(path string)
+}
+
+// Rules used when nothing else is specified:
+// arrays are concatenated, tag 'values' are deduplicated and 'replace: true' is honored.
+func DefaultMergeRules() MergeRules {
+	return MergeRules{
+		Arrays:       MERGE_APPEND,
+		Paths:        map[string]MergeStrategy{"values": MERGE_UNION},
+		HonorReplace: true,
+	}
+}
+
+func ParseMergeStrategy(value string) (MergeStrategy, error) {
+	for _, strategy := range MergeStrategies {
+		if string(strategy) == value {
+			return strategy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown merge strategy %q, expected one of %q", value, MergeStrategies)
+}
+
+// Returns the strategy of the path made of [segments].
+// The pattern with the fewest wildcards wins if several match.
+func (rules MergeRules) strategyOf(segments []string) MergeStrategy {
+	best, best_pattern, best_wildcards := MERGE_DEEP, "", len(segments)+1
+	for pattern, strategy := range rules.Paths {
+		if !matchesPath(strings.Split(pattern, "."), segments) {
+			continue
+		}
+		wildcards := strings.Count(pattern, "*")
+		if wildcards < best_wildcards || (wildcards == best_wildcards && pattern < best_pattern) {
+			best, best_pattern, best_wildcards = strategy, pattern, wildcards
+		}
+	}
+	return best
+}
+
+func matchesPath(pattern, segments []string) bool {
+	if len(pattern) != len(segments) {
+		return false
+	}
+	for i, part := range pattern {
+		if part != "*" && part != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Recursively merges [target] into the file according to [rules]
+func (file *JsonFile) MergeWith(target *JsonFile, rules MergeRules) {
+	if rules.HonorReplace && target.Get("replace").Bool() {
+		file.Body = append([]byte(nil), target.Body...)
+		return
+	}
+
+	origin, value := file.Get("@this"), target.Get("@this")
+	if !origin.IsObject() || !value.IsObject() {
+		if rules.OnConflict != nil && !isSameValue(origin, value) {
+			rules.OnConflict("")
+		}
+		file.Body = append([]byte(nil), target.Body...)
+		return
+	}
+
+	file.mergeObject(nil, value, rules)
+}
+
+func (file *JsonFile) mergeObject(segments []string, object gjson.Result, rules MergeRules) {
+	object.ForEach(func(key, value gjson.Result) bool {
+		segments := append(segments[:len(segments):len(segments)], key.String())
+		file.mergeValue(segments, value, rules)
+		return true
+	})
+}
+
+func (file *JsonFile) mergeValue(segments []string, value gjson.Result, rules MergeRules) {
+	path := toJsonPath(segments)
+	origin := file.Get(path)
+	strategy := rules.strategyOf(segments)
+
+	switch {
+
+	case !origin.Exists():
+		file.SetRaw(path, value.Raw)
+
+	case strategy == MERGE_KEEP:
+
+	case strategy == MERGE_OVERRIDE:
+		file.SetRaw(path, value.Raw)
+
+	case origin.IsObject() && value.IsObject():
+		file.mergeObject(segments, value, rules)
+
+	case origin.IsArray() && value.IsArray():
+		if strategy == MERGE_DEEP {
+			strategy = rules.Arrays
+		}
+		switch strategy {
+		case MERGE_APPEND:
+			file.SetRaw(path, joinArrays(origin, value, false))
+		case MERGE_UNION:
+			file.SetRaw(path, joinArrays(origin, value, true))
+		default:
+			if rules.OnConflict != nil && !isSameValue(origin, value) {
+				rules.OnConflict(strings.Join(segments, "."))
+			}
+			file.SetRaw(path, value.Raw)
+		}
+
+	default:
+		if rules.OnConflict != nil && !isSameValue(origin, value) {
+			rules.OnConflict(strings.Join(segments, "."))
+		}
+		file.SetRaw(path, value.Raw)
+	}
+}
+
+// Concatenates two arrays, skipping items already present if [unique].
+// Objects with an 'id' field (as in tags) are identified by it.
+func joinArrays(origin, value gjson.Result, unique bool) string {
+	var builder strings.Builder
+	seen := map[string]bool{}
+	builder.WriteByte('[')
+
+	for _, item := range append(origin.Array(), value.Array()...) {
+		if unique {
+			id := identityOf(item)
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+		}
+
+		if builder.Len() > 1 {
+			builder.WriteByte(',')
+		}
+		builder.WriteString(item.Raw)
+	}
+
+	builder.WriteByte(']')
+	return builder.String()
+}
+
+func identityOf(item gjson.Result) string {
+	if item.IsObject() && item.Get("id").Type == gjson.String {
+		return item.Get("id").String()
+	}
+	if item.Type == gjson.String {
+		return item.String()
+	}
+	return string(pretty.Ugly([]byte(item.Raw)))
+}
+
+func isSameValue(a, b gjson.Result) bool {
+	return string(pretty.Ugly([]byte(a.Raw))) == string(pretty.Ugly([]byte(b.Raw)))
+}
+
+func toJsonPath(segments []string) string {
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = EscapeKey(segment)
+	}
+	return strings.Join(escaped, ".")
+}
+
+var keyEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`.`, `\.`,
+	`*`, `\*`,
+	`?`, `\?`,
+	`|`, `\|`,
+	`#`, `\#`,
+	`@`, `\@`,
+	`!`, `\!`,
+	`:`, `\:`,
+)
+
+// Escapes [key] to be used as a single segment of a gjson/sjson path
+func EscapeKey(key string) string {
+	return keyEscaper.Replace(key)
+}
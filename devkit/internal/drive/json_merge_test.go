@@ -0,0 +1,72 @@
+package drive_test
+
+import (
+	"testing"
+
+	"github.com/bbfh-dev/vintage/devkit/internal/drive"
+	"github.com/tidwall/pretty"
+	"gotest.tools/assert"
+)
+
+func TestMergeWith(t *testing.T) {
+	cases := []struct {
+		origin, target string
+		rules          drive.MergeRules
+		expect         string
+	}{
+		{
+			`{"a": {"b": 1, "c": [1]}}`,
+			`{"a": {"d": 2, "c": [2]}}`,
+			drive.DefaultMergeRules(),
+			`{"a":{"b":1,"c":[1,2],"d":2}}`,
+		},
+		{
+			`{"values": ["a:x", "a:y"]}`,
+			`{"values": [{"id": "a:y", "required": false}, "a:z"]}`,
+			drive.DefaultMergeRules(),
+			`{"values":["a:x","a:y","a:z"]}`,
+		},
+		{
+			`{"values": ["a:x"]}`,
+			`{"replace": true, "values": ["a:z"]}`,
+			drive.DefaultMergeRules(),
+			`{"replace":true,"values":["a:z"]}`,
+		},
+		{
+			`{"textures": {"a": {"x": 1}}, "list": [1]}`,
+			`{"textures": {"a": {"y": 2}}, "list": [2]}`,
+			drive.MergeRules{
+				Arrays: drive.MERGE_APPEND,
+				Paths: map[string]drive.MergeStrategy{
+					"textures.*": drive.MERGE_OVERRIDE,
+					"list":       drive.MERGE_KEEP,
+				},
+			},
+			`{"textures":{"a":{"y":2}},"list":[1]}`,
+		},
+		{
+			`{"a.b": {"c": 1}}`,
+			`{"a.b": {"d": 2}}`,
+			drive.DefaultMergeRules(),
+			`{"a.b":{"c":1,"d":2}}`,
+		},
+	}
+
+	for _, test := range cases {
+		file := drive.NewJsonFile([]byte(test.origin))
+		file.MergeWith(drive.NewJsonFile([]byte(test.target)), test.rules)
+		assert.Equal(t, string(pretty.Ugly(file.Body)), test.expect)
+	}
+}
+
+func TestMergeWithConflicts(t *testing.T) {
+	conflicts := []string{}
+	rules := drive.MergeRules{
+		Arrays:     drive.MERGE_OVERRIDE,
+		OnConflict: func(path string) { conflicts = append(conflicts, path) },
+	}
+
+	file := drive.NewJsonFile([]byte(`{"a": 1, "b": {"c": "x", "d": [1]}, "e": true}`))
+	file.MergeWith(drive.NewJsonFile([]byte(`{"a": 1, "b": {"c": "y", "d": [2]}, "e": true}`)), rules)
+	assert.DeepEqual(t, conflicts, []string{"b.c", "b.d"})
+}
@@ -0,0 +1,60 @@
+package drive
+
+import (
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+type FileStamp struct {
+	ModTime time.Time
+	Size    int64
+}
+
+// Maps file paths to their last known modification time and size
+type Snapshot map[string]FileStamp
+
+// Records every file inside of the provided paths. Missing paths are ignored.
+func TakeSnapshot(paths ...string) Snapshot {
+	snapshot := Snapshot{}
+	for _, path := range paths {
+		filepath.WalkDir(path, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return nil
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				return nil
+			}
+
+			snapshot[path] = FileStamp{
+				ModTime: info.ModTime(),
+				Size:    info.Size(),
+			}
+			return nil
+		})
+	}
+	return snapshot
+}
+
+// Returns sorted lists of files that were created/modified and removed since [old]
+func (snapshot Snapshot) Diff(old Snapshot) (changed []string, removed []string) {
+	for path, stamp := range snapshot {
+		if old_stamp, ok := old[path]; !ok || !old_stamp.ModTime.Equal(stamp.ModTime) ||
+			old_stamp.Size != stamp.Size {
+			changed = append(changed, path)
+		}
+	}
+
+	for path := range old {
+		if _, ok := snapshot[path]; !ok {
+			removed = append(removed, path)
+		}
+	}
+
+	slices.Sort(changed)
+	slices.Sort(removed)
+	return changed, removed
+}
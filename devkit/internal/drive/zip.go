@@ -0,0 +1,234 @@
+package drive
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/zip"
+)
+
+// The earliest time representable in the MS-DOS format used by .zip files
+var ZipEpoch = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Compression level of .zip files when [ZipOptions.Level] is not set
+const DEFAULT_COMPRESSION_LEVEL = 6
+
+// Stores files in .zip files without compressing them
+const NO_COMPRESSION = -1
+
+type ZipOptions struct {
+	// Fixes timestamps and permissions so that identical inputs give identical bytes
+	Deterministic bool
+	// From 1 (fastest) to 9 (best compression) or [NO_COMPRESSION]. 0 means [DEFAULT_COMPRESSION_LEVEL]
+	Level int
+}
+
+// Returns the timestamp used by deterministic archives.
+// Respects $SOURCE_DATE_EPOCH (https://reproducible-builds.org/specs/source-date-epoch/).
+func ZipTimestamp() time.Time {
+	if value, ok := os.LookupEnv("SOURCE_DATE_EPOCH"); ok {
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err == nil && time.Unix(seconds, 0).After(ZipEpoch) {
+			return time.Unix(seconds, 0).UTC()
+		}
+	}
+	return ZipEpoch
+}
+
+type zipWriter struct {
+	*zip.Writer
+	options   ZipOptions
+	method    uint16
+	timestamp time.Time
+}
+
+func newZipWriter(out io.Writer, options ZipOptions) *zipWriter {
+	level := options.Level
+	if level == 0 {
+		level = DEFAULT_COMPRESSION_LEVEL
+	}
+	writer := zip.NewWriter(out)
+	writer.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, level)
+	})
+
+	method := zip.Deflate
+	if level == NO_COMPRESSION {
+		method = zip.Store
+	}
+
+	return &zipWriter{
+		Writer:    writer,
+		options:   options,
+		method:    method,
+		timestamp: ZipTimestamp(),
+	}
+}
+
+// Creates an entry for [name] ('/' separated). Directory names must end with '/'.
+func (writer *zipWriter) create(name string, info fs.FileInfo) (io.Writer, error) {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return nil, err
+	}
+	header.Name = name
+	header.Method = writer.method
+
+	if info.IsDir() {
+		header.Method = zip.Store
+	}
+	if writer.options.Deterministic {
+		header.Modified = writer.timestamp
+		header.SetMode(normalizedMode(info.IsDir()))
+	}
+
+	return writer.CreateHeader(header)
+}
+
+// Writes every file inside of [fsys] into [out] as a .zip archive.
+// Entries are always sorted by path (as guaranteed by [fs.WalkDir]).
+func WriteZip(fsys fs.FS, out io.Writer, options ZipOptions) error {
+	writer := newZipWriter(out, options)
+
+	err := fs.WalkDir(fsys, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || path == "." {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		name := filepath.ToSlash(path)
+		if entry.IsDir() {
+			name += "/"
+		}
+		file_writer, err := writer.create(name, info)
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		file, err := fsys.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(file_writer, file)
+		return err
+	})
+	if err != nil {
+		writer.Close()
+		return err
+	}
+
+	return writer.Close()
+}
+
+// Same as [WriteZip] but takes in-memory files ('/' separated path → contents).
+// Parent directories are created implicitly.
+func WriteZipFiles(files map[string][]byte, out io.Writer, options ZipOptions) error {
+	entries := map[string]fs.FileInfo{}
+	now := time.Now()
+	for name, body := range files {
+		entries[name] = memoryFileInfo{name: path.Base(name), size: len(body), modTime: now}
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			entries[dir+"/"] = memoryFileInfo{name: path.Base(dir), isDir: true, modTime: now}
+		}
+	}
+
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		return strings.Compare(strings.TrimSuffix(a, "/"), strings.TrimSuffix(b, "/"))
+	})
+
+	writer := newZipWriter(out, options)
+	for _, name := range names {
+		file_writer, err := writer.create(name, entries[name])
+		if err != nil {
+			writer.Close()
+			return err
+		}
+		if strings.HasSuffix(name, "/") {
+			continue
+		}
+		if _, err := io.Copy(file_writer, bytes.NewReader(files[name])); err != nil {
+			writer.Close()
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+// Reads every file of a .zip archive into memory ('/' separated path → contents)
+func ReadZipFiles(body []byte) (map[string][]byte, error) {
+	reader, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{}
+	for _, file := range reader.File {
+		if strings.HasSuffix(file.Name, "/") {
+			continue
+		}
+
+		body, err := readZipFile(file)
+		if err != nil {
+			return nil, err
+		}
+		files[strings.TrimPrefix(file.Name, "./")] = body
+	}
+
+	return files, nil
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+func normalizedMode(is_dir bool) fs.FileMode {
+	if is_dir {
+		return fs.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+type memoryFileInfo struct {
+	name    string
+	size    int
+	isDir   bool
+	modTime time.Time
+}
+
+func (info memoryFileInfo) Name() string       { return info.name }
+func (info memoryFileInfo) Size() int64        { return int64(info.size) }
+func (info memoryFileInfo) ModTime() time.Time { return info.modTime }
+func (info memoryFileInfo) IsDir() bool        { return info.isDir }
+func (info memoryFileInfo) Sys() any           { return nil }
+
+func (info memoryFileInfo) Mode() fs.FileMode {
+	if info.isDir {
+		return fs.ModeDir | 0o755
+	}
+	return 0o644
+}
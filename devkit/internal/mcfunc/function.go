@@ -11,16 +11,28 @@ import (
 var FunctionPool = drive.NewPool[Function](drive.DEFAULT_POOL_SIZE, drive.DEFAULT_POOL_SIZE)
 
 type Function struct {
-	Path      string
+	Path string
+	// The file this function originates from (used to track dependencies).
+	// Same as [Function.Path] unless it was generated from a template.
+	Source    string
 	Scanner   *templates.BufferedScanner
 	Templates map[string]*templates.Inline
+	// Where the emitted lines are collected
+	Registry *Registry
 }
 
-func New(path string, scanner *bufio.Scanner, registry map[string]*templates.Inline) *Function {
+func New(
+	path string,
+	scanner *bufio.Scanner,
+	inline_templates map[string]*templates.Inline,
+	registry *Registry,
+) *Function {
 	return FunctionPool.Acquire(func(fn *Function) {
 		fn.Path = path
+		fn.Source = path
 		fn.Scanner = templates.NewBufferedScanner(scanner)
-		fn.Templates = registry
+		fn.Templates = inline_templates
+		fn.Registry = registry
 	})
 }
 
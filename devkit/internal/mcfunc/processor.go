@@ -1,8 +1,10 @@
 package mcfunc
 
 import (
+	"bufio"
 	"fmt"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	liberrors "github.com/bbfh-dev/lib-errors"
@@ -14,13 +16,25 @@ import (
 
 var ProcessorPool = drive.NewPool[Processor](drive.DEFAULT_POOL_SIZE, drive.DEFAULT_POOL_SIZE)
 
+// How deep inline templates can call each other from their output
+const MAX_INLINE_DEPTH = 16
+
 type Processor struct {
 	Function *Function
+	// Templates whose output is being expanded, outermost first
+	chain []string
+	// The call in the function that the expanded output originates from
+	origin templates.CallSite
+	// Number of inline template calls made so far
+	calls uint
 }
 
 func NewProcessor(fn *Function) *Processor {
 	return ProcessorPool.Acquire(func(proc *Processor) {
 		proc.Function = fn
+		proc.chain = nil
+		proc.origin = templates.CallSite{}
+		proc.calls = 0
 	})
 }
 
@@ -59,12 +73,17 @@ func (proc *Processor) ExecInlineTemplates(
 			return nil
 		}
 
+		if _, ok := templates.CutSlotMarker(clean_line); ok {
+			return proc.errStraySlotMarker(clean_line)
+		}
+
 		if !templates.IsInlineCall(clean_line) {
 			output.Writeln(aligned_line)
 			continue
 		}
 
-		contents := clean_line[len(templates.INLINE_CALL_PREFIX):]
+		// The name can be separated from the prefix, e.g. "#~> log"
+		contents := strings.TrimLeft(clean_line[len(templates.INLINE_CALL_PREFIX):], " \t")
 		if len(contents) == 0 {
 			return proc.errEmptyTemplateCall(clean_line)
 		}
@@ -75,17 +94,28 @@ func (proc *Processor) ExecInlineTemplates(
 		if !ok {
 			return proc.errUndefinedTemplate(clean_line, name)
 		}
+		if slices.Contains(proc.chain, name) {
+			return proc.errTemplateCycle(clean_line, name)
+		}
+		if len(proc.chain) >= MAX_INLINE_DEPTH {
+			return proc.errTemplateDepth(clean_line, name)
+		}
 
 		var call_args []string
 		if template.IsArgPassthrough() {
 			call_args = []string{strings.TrimSpace(contents[len(name):])}
 		} else {
-			call_args = in_args[1:]
-			if len(call_args) != len(template.RequiredArgs) {
-				return proc.errMismatchArgs(clean_line, name, template, call_args)
+			bound, err := template.Bind(in_args[1:])
+			if err != nil {
+				return proc.errMismatchArgs(clean_line, name, template, err)
 			}
+			call_args = bound
 		}
 
+		// Counted before the body, so that calls are numbered in the order they appear
+		proc.calls++
+		counter := proc.calls
+		line_number := proc.Function.Scanner.LineNumber
 		buffer_indent := output.Indent
 		output.SetIndent(line_indent)
 
@@ -95,18 +125,110 @@ func (proc *Processor) ExecInlineTemplates(
 			return err
 		}
 
-		err = template.Call(output, call_buffer, call_args)
+		slots, err := proc.execSlots(template, name, root_indent, line_indent)
+		if err != nil {
+			return err
+		}
+
+		site := templates.CallSite{
+			Path: proc.Function.Path,
+			Line: line_number,
+			Text: clean_line,
+			MakeErrorContext: func(offset uint) liberrors.Context {
+				line := clean_line
+				if offset != 0 && int(offset) <= len(call_buffer.Lines) {
+					line = strings.TrimSpace(call_buffer.Lines[offset-1])
+				}
+				return proc.Function.MakeErrorContext(line_number+offset, line)
+			},
+		}
+		// Lines emitted by templates are not in the function, so the call they originate from is pointed at
+		if len(proc.chain) != 0 {
+			site = proc.origin
+		}
+		site.Counter = counter
+		site.Slots = slots
+
+		call_output := templates.NewBuffer()
+		err = template.Call(call_output, call_buffer, call_args, site)
+		if err != nil {
+			return err
+		}
+
+		expanded, err := proc.expand(call_output, name, site)
 		if err != nil {
 			return err
 		}
+		for _, line := range expanded.Lines {
+			output.Writeln(line)
+		}
 		output.Indent = buffer_indent
 	}
 
 	return nil
 }
 
+// Collects the sections given after "#~>> name" markers that follow the body of a call to the template [name].
+// Markers are aligned with the call, which is [call_indent] deeper than [root_indent].
+func (proc *Processor) execSlots(
+	template *templates.Inline,
+	name string,
+	root_indent, call_indent int,
+) (map[string]*templates.Buffer, error) {
+	var slots map[string]*templates.Buffer
+	for proc.Function.Scanner.Scan() {
+		raw_line := proc.Function.Scanner.Text()
+		clean_line := strings.TrimSpace(raw_line)
+		slot, ok := templates.CutSlotMarker(clean_line)
+		if !ok || code.GetIndentOf(raw_line)-root_indent != call_indent {
+			proc.Function.Scanner.Unscan()
+			break
+		}
+
+		if !template.HasMarkedSlot(slot) {
+			return nil, proc.errUndefinedSlot(clean_line, name, template, slot)
+		}
+		if _, ok := slots[slot]; ok {
+			return nil, proc.errDuplicateSlot(clean_line, slot)
+		}
+
+		section := templates.NewBuffer()
+		if err := proc.ExecInlineTemplates(section, call_indent, false); err != nil {
+			return nil, err
+		}
+		if slots == nil {
+			slots = map[string]*templates.Buffer{}
+		}
+		slots[slot] = section
+	}
+	return slots, nil
+}
+
+// Expands the inline template calls inside of what the template [name] emitted when called from [site]
+func (proc *Processor) expand(emitted *templates.Buffer, name string, site templates.CallSite) (*templates.Buffer, error) {
+	has_calls := slices.ContainsFunc(emitted.Lines, func(line string) bool {
+		return templates.IsInlineCall(strings.TrimSpace(line))
+	})
+	if !has_calls {
+		return emitted, nil
+	}
+
+	scanner, chain, origin := proc.Function.Scanner, proc.chain, proc.origin
+	defer func() {
+		proc.Function.Scanner, proc.chain, proc.origin = scanner, chain, origin
+	}()
+
+	proc.Function.Scanner = templates.NewBufferedScanner(bufio.NewScanner(emitted.Reader()))
+	proc.chain = slices.Concat(chain, []string{name})
+	proc.origin = site
+
+	expanded := templates.NewBuffer()
+	return expanded, proc.ExecInlineTemplates(expanded, 0, true)
+}
+
 func (proc *Processor) Inline(input *templates.Buffer) error {
 	current_path := proc.Function.Path
+	prefix := internal.PathPrefix(current_path)
 	breadcrumbs := []string{current_path}
 	current_resource := internal.PathToResource(current_path)
 	current_indent := 0
@@ -118,7 +240,7 @@ func (proc *Processor) Inline(input *templates.Buffer) error {
 		clean_line := strings.TrimSpace(formatted_line)
 
 		if line_indent == 0 || clean_line == "" {
-			AddLine(breadcrumbs[len(breadcrumbs)-1], clean_line)
+			proc.Function.Registry.AddLine(proc.Function.Source, breadcrumbs[len(breadcrumbs)-1], clean_line)
 			continue
 		}
 
@@ -139,7 +261,7 @@ func (proc *Processor) Inline(input *templates.Buffer) error {
 
 			previous_line := input.Lines[i-1]
 			if strings.HasSuffix(strings.TrimRight(previous_line, " "), "\\") {
-				AddLine(breadcrumbs[len(breadcrumbs)-1], formatted_line)
+				proc.Function.Registry.AddLine(proc.Function.Source, breadcrumbs[len(breadcrumbs)-1], formatted_line)
 				continue
 			}
 
@@ -164,9 +286,9 @@ func (proc *Processor) Inline(input *templates.Buffer) error {
 				}
 			}
 
-			breadcrumbs = append(breadcrumbs, filepath.Join("data", path+".mcfunction"))
+			breadcrumbs = append(breadcrumbs, filepath.Join(prefix, "data", path+".mcfunction"))
 			current_indent += line_indent
-			AddLine(breadcrumbs[len(breadcrumbs)-1], clean_line)
+			proc.Function.Registry.AddLine(proc.Function.Source, breadcrumbs[len(breadcrumbs)-1], clean_line)
 			continue
 		}
 	}
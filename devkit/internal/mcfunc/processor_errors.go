@@ -2,19 +2,25 @@ package mcfunc
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
 	liberrors "github.com/bbfh-dev/lib-errors"
 	"github.com/bbfh-dev/vintage/devkit/internal/templates"
 )
 
+// Points at [clean_line], or at the call in the function if it was emitted by a template
+func (proc *Processor) makeErrorContext(clean_line string) liberrors.Context {
+	if len(proc.chain) != 0 {
+		return proc.origin.ErrorContext(0)
+	}
+	return proc.Function.MakeErrorContext(proc.Function.Scanner.LineNumber, clean_line)
+}
+
 func (proc *Processor) errEmptyTemplateCall(clean_line string) error {
 	return &liberrors.DetailedError{
-		Label: liberrors.ERR_SYNTAX,
-		Context: proc.Function.MakeErrorContext(
-			proc.Function.Scanner.LineNumber,
-			clean_line,
-		),
+		Label:   liberrors.ERR_SYNTAX,
+		Context: proc.makeErrorContext(clean_line),
 		Details: fmt.Sprintf(
 			"%q expects to run an inline template, but it's not followed by anything.",
 			templates.INLINE_CALL_PREFIX,
@@ -24,11 +30,8 @@ func (proc *Processor) errEmptyTemplateCall(clean_line string) error {
 
 func (proc *Processor) errUndefinedTemplate(clean_line, name string) error {
 	return &liberrors.DetailedError{
-		Label: liberrors.ERR_SYNTAX,
-		Context: proc.Function.MakeErrorContext(
-			proc.Function.Scanner.LineNumber,
-			clean_line,
-		),
+		Label:   liberrors.ERR_SYNTAX,
+		Context: proc.makeErrorContext(clean_line),
 		Details: fmt.Sprintf("undefined inline template %q", name),
 	}
 }
@@ -36,21 +39,66 @@ func (proc *Processor) errUndefinedTemplate(clean_line, name string) error {
 func (proc *Processor) errMismatchArgs(
 	clean_line, name string,
 	template *templates.Inline,
-	call_args []string,
+	err error,
 ) error {
 	return &liberrors.DetailedError{
-		Label: liberrors.ERR_VALIDATE,
-		Context: proc.Function.MakeErrorContext(
-			proc.Function.Scanner.LineNumber,
-			clean_line,
+		Label:   liberrors.ERR_VALIDATE,
+		Context: proc.makeErrorContext(clean_line),
+		Details: fmt.Sprintf("template %q (%s): %s", name, template.Signature(), err),
+	}
+}
+
+func (proc *Processor) errTemplateCycle(clean_line, name string) error {
+	return &liberrors.DetailedError{
+		Label:   liberrors.ERR_SYNTAX,
+		Context: proc.makeErrorContext(clean_line),
+		Details: fmt.Sprintf(
+			"inline templates call each other in a cycle: %s",
+			strings.Join(append(slices.Clone(proc.chain), name), " → "),
 		),
+	}
+}
+
+func (proc *Processor) errTemplateDepth(clean_line, name string) error {
+	return &liberrors.DetailedError{
+		Label:   liberrors.ERR_SYNTAX,
+		Context: proc.makeErrorContext(clean_line),
 		Details: fmt.Sprintf(
-			"template %q requires %d arguments (%s), but got %d (%s)",
-			name,
-			len(template.RequiredArgs),
-			strings.Join(template.RequiredArgs, " "),
-			len(call_args),
-			strings.Join(call_args, " "),
+			"inline templates are nested deeper than %d: %s",
+			MAX_INLINE_DEPTH,
+			strings.Join(append(slices.Clone(proc.chain), name), " → "),
 		),
 	}
 }
+
+func (proc *Processor) errStraySlotMarker(clean_line string) error {
+	return &liberrors.DetailedError{
+		Label:   liberrors.ERR_SYNTAX,
+		Context: proc.makeErrorContext(clean_line),
+		Details: "slot marker must follow the body of an inline template call, aligned with the call",
+	}
+}
+
+func (proc *Processor) errUndefinedSlot(
+	clean_line, name string,
+	template *templates.Inline,
+	slot string,
+) error {
+	slots := "none"
+	if len(template.Slots) > 1 {
+		slots = strings.Join(template.Slots[1:], ", ")
+	}
+	return &liberrors.DetailedError{
+		Label:   liberrors.ERR_VALIDATE,
+		Context: proc.makeErrorContext(clean_line),
+		Details: fmt.Sprintf("template %q has no slot %q given by a marker (slots: %s)", name, slot, slots),
+	}
+}
+
+func (proc *Processor) errDuplicateSlot(clean_line, slot string) error {
+	return &liberrors.DetailedError{
+		Label:   liberrors.ERR_SYNTAX,
+		Context: proc.makeErrorContext(clean_line),
+		Details: fmt.Sprintf("slot %q is given more than once", slot),
+	}
+}
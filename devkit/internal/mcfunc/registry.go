@@ -5,33 +5,49 @@ import (
 	"sync"
 )
 
-var UsedNamespaces = map[string]byte{}
-var Registry = map[string][]string{}
+// Collects the lines of every function emitted during a single build
+type Registry struct {
+	mutex sync.Mutex
+	// Maps function paths to the lines contributed by every source file
+	Functions map[string]map[string][]string
+	// Maps source files to the namespaces used by their lines
+	UsedNamespaces map[string]map[string]byte
+}
 
-var mutex = sync.Mutex{}
+func NewRegistry() *Registry {
+	return &Registry{
+		Functions:      map[string]map[string][]string{},
+		UsedNamespaces: map[string]map[string]byte{},
+	}
+}
 
-func AddLine(path string, line string) error {
-	mutex.Lock()
-	defer mutex.Unlock()
+func (registry *Registry) AddLine(source, path string, line string) error {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
 
-	lines, ok := Registry[path]
+	sources, ok := registry.Functions[path]
 	if !ok {
-		Registry[path] = []string{}
+		sources = map[string][]string{}
+		registry.Functions[path] = sources
 	}
 
-	collectNamespaces(line)
-	Registry[path] = append(lines, line)
+	registry.collectNamespaces(source, line)
+	sources[source] = append(sources[source], line)
 	return nil
 }
 
-func collectNamespaces(line string) {
+func (registry *Registry) collectNamespaces(source, line string) {
 	for field := range strings.FieldsSeq(line) {
 		if strings.ContainsAny(field, "@[]=!") {
 			continue
 		}
 		before, after, ok := strings.Cut(field, ":")
 		if ok && after != "" && strings.ToLower(before) == before {
-			UsedNamespaces[strings.TrimPrefix(before, "#")] = 1
+			namespace := strings.TrimPrefix(before, "#")
+			if registry.UsedNamespaces[source] == nil {
+				registry.UsedNamespaces[source] = map[string]byte{}
+			}
+			registry.UsedNamespaces[source][namespace] = 1
 		}
 	}
 }
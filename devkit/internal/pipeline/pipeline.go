@@ -1,8 +1,9 @@
 package pipeline
 
 import (
+	"context"
+
 	liberrors "github.com/bbfh-dev/lib-errors"
-	"github.com/bbfh-dev/vintage/devkit/internal/drive"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -10,10 +11,18 @@ type Task func() error
 
 // Pipeline calls the functions in order and returns the first encountered error
 func New(tasks ...Task) error {
+	return WithContext(context.Background(), tasks...)
+}
+
+// Same as [New], but stops before the next task once [ctx] is done
+func WithContext(ctx context.Context, tasks ...Task) error {
 	for _, task := range tasks {
 		if task == nil {
 			continue
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if err := task(); err != nil {
 			return err
 		}
@@ -57,11 +66,10 @@ func Async(tasks ...AsyncTask) Task {
 			case *liberrors.DetailedError:
 				return err
 			default:
+				// Tasks run inside of the project, whose location is unknown here
 				return &liberrors.DetailedError{
-					Label: "Task Error",
-					Context: liberrors.DirContext{
-						Path: drive.ToAbs("data"),
-					},
+					Label:   "Task Error",
+					Context: nil,
 					Details: err.Error(),
 				}
 			}
@@ -0,0 +1,182 @@
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Types that arguments can be declared with in the 'type' field
+const (
+	ARG_STRING    = "string"
+	ARG_INT       = "int"
+	ARG_RANGE     = "range"
+	ARG_RESOURCE  = "resource"
+	ARG_OBJECTIVE = "objective"
+	ARG_SELECTOR  = "selector"
+	ARG_NBT_PATH  = "nbt_path"
+	ARG_JSON      = "json"
+)
+
+var argumentTypes = []string{
+	ARG_STRING,
+	ARG_INT,
+	ARG_RANGE,
+	ARG_RESOURCE,
+	ARG_OBJECTIVE,
+	ARG_SELECTOR,
+	ARG_NBT_PATH,
+	ARG_JSON,
+}
+
+var (
+	resourcePattern   = regexp.MustCompile(`^(?:[a-z0-9_.-]+:)?[a-z0-9_./-]+$`)
+	objectivePattern  = regexp.MustCompile(`^[A-Za-z0-9_.+-]+$`)
+	playerNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,16}$`)
+	uuidPattern       = regexp.MustCompile(`^[0-9a-fA-F]{1,8}-[0-9a-fA-F]{1,4}-[0-9a-fA-F]{1,4}-[0-9a-fA-F]{1,4}-[0-9a-fA-F]{1,12}$`)
+	selectorPattern   = regexp.MustCompile(`^@[aenprs](?:\[.*\])?$`)
+)
+
+// Checks that [value] is of the type of the argument and satisfies its constraints
+func (argument Argument) Validate(value string) error {
+	if err := validateType(argument.Type, value); err != nil {
+		return fmt.Errorf("argument %q %s, but got %q", argument.Name, err, value)
+	}
+
+	if len(argument.Enum) != 0 && !slices.Contains(argument.Enum, value) {
+		return fmt.Errorf(
+			"argument %q must be one of (%s), but got %q",
+			argument.Name,
+			strings.Join(argument.Enum, ", "),
+			value,
+		)
+	}
+	if argument.Pattern != nil && !argument.Pattern.MatchString(value) {
+		return fmt.Errorf("argument %q must match %q, but got %q", argument.Name, argument.Pattern, value)
+	}
+
+	bounds := []string{value}
+	if argument.Type == ARG_RANGE {
+		bounds = strings.SplitN(value, "..", 2)
+	}
+	for _, bound := range bounds {
+		if bound == "" {
+			continue
+		}
+		number, _ := strconv.Atoi(bound)
+		if argument.Min != nil && number < *argument.Min {
+			return fmt.Errorf("argument %q must be at least %d, but got %q", argument.Name, *argument.Min, value)
+		}
+		if argument.Max != nil && number > *argument.Max {
+			return fmt.Errorf("argument %q must be at most %d, but got %q", argument.Name, *argument.Max, value)
+		}
+	}
+
+	return nil
+}
+
+// Returns what [value] must be to be of type [kind], or nil if it is
+func validateType(kind, value string) error {
+	switch kind {
+
+	case ARG_INT:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+
+	case ARG_RANGE:
+		min, max, is_range := strings.Cut(value, "..")
+		min_value, min_err := strconv.Atoi(min)
+		max_value, max_err := strconv.Atoi(max)
+		switch {
+		case !is_range && min_err == nil:
+		case is_range && (min_err == nil || min == "") && (max_err == nil || max == "") && value != "..":
+			if min != "" && max != "" && min_value > max_value {
+				return fmt.Errorf("must be a range with its minimum not greater than its maximum")
+			}
+		default:
+			return fmt.Errorf("must be an integer range (e.g. 1..5, ..5, 1.. or 3)")
+		}
+
+	case ARG_RESOURCE:
+		if !resourcePattern.MatchString(value) {
+			return fmt.Errorf("must be a resource location (e.g. namespace:path)")
+		}
+
+	case ARG_OBJECTIVE:
+		if !objectivePattern.MatchString(value) {
+			return fmt.Errorf("must be a scoreboard objective (letters, digits and _ . + -)")
+		}
+
+	case ARG_SELECTOR:
+		is_selector := selectorPattern.MatchString(value) && isBalanced(value[2:])
+		if !is_selector && !playerNamePattern.MatchString(value) && !uuidPattern.MatchString(value) {
+			return fmt.Errorf("must be a target selector, player name or UUID")
+		}
+
+	case ARG_NBT_PATH:
+		if value == "" || !isBalanced(value) || strings.ContainsAny(unquoted(value), " \t") {
+			return fmt.Errorf("must be an NBT path (e.g. Inventory[0].id)")
+		}
+
+	case ARG_JSON:
+		if !gjson.Valid(value) {
+			return fmt.Errorf("must be valid JSON")
+		}
+	}
+
+	return nil
+}
+
+// Whether every bracket of [value] outside of quotes is closed in the right order
+func isBalanced(value string) bool {
+	closing := []rune{}
+	for _, char := range unquoted(value) {
+		switch char {
+		case '[':
+			closing = append(closing, ']')
+		case '{':
+			closing = append(closing, '}')
+		case '(':
+			closing = append(closing, ')')
+		case ']', '}', ')':
+			if len(closing) == 0 || closing[len(closing)-1] != char {
+				return false
+			}
+			closing = closing[:len(closing)-1]
+		}
+	}
+	return len(closing) == 0
+}
+
+// Removes the contents of quoted strings from [value], keeping the quotes.
+// An unterminated string leaves an unclosed bracket, so that it is never balanced.
+func unquoted(value string) string {
+	var builder strings.Builder
+	var quote rune
+	is_escaped := false
+	for _, char := range value {
+		switch {
+		case quote == 0:
+			builder.WriteRune(char)
+			if char == '"' || char == '\'' {
+				quote = char
+			}
+		case is_escaped:
+			is_escaped = false
+		case char == '\\':
+			is_escaped = true
+		case char == quote:
+			builder.WriteRune(char)
+			quote = 0
+		}
+	}
+	if quote != 0 {
+		builder.WriteRune('[')
+	}
+	return builder.String()
+}
@@ -0,0 +1,263 @@
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/bbfh-dev/vintage/devkit/internal/code"
+	"github.com/tidwall/gjson"
+)
+
+// An argument of an inline template, declared in the 'arguments' field of its manifest
+// either as a name (required) or as an object:
+//
+//	{"name": "count", "default": "1"}
+//	{"name": "target", "optional": true}
+//	{"name": "rest", "variadic": true}
+//	{"name": "slot", "type": "int", "min": 0, "max": 35}
+//
+// Values of typed arguments are validated when the template is called.
+type Argument struct {
+	Name string
+	// One of ARG_*, values of [ARG_JSON] are parsed before being substituted
+	Type string
+	// Bounds of an [ARG_INT] or [ARG_RANGE] value
+	Min *int
+	Max *int
+	// Values that are allowed, if not empty
+	Enum []string
+	// Pattern that the whole value must match, if not nil
+	Pattern *regexp.Regexp
+	// Value of an optional argument that is not given
+	Default    string
+	IsOptional bool
+	// Takes every remaining positional value, must be the last argument
+	IsVariadic bool
+}
+
+func (argument Argument) String() string {
+	name := argument.Name
+	if argument.Type != "" && argument.Type != ARG_STRING {
+		name += ":" + argument.Type
+	}
+
+	switch {
+	case argument.IsVariadic:
+		return name + "..."
+	case argument.IsOptional && argument.Default != "":
+		return fmt.Sprintf("[%s=%s]", name, argument.Default)
+	case argument.IsOptional:
+		return "[" + name + "]"
+	}
+	return name
+}
+
+func parseArguments(dir string, field gjson.Result) ([]Argument, error) {
+	if !field.IsArray() {
+		return nil, newSyntaxError(dir, "field 'arguments' must be an array", field)
+	}
+
+	arguments := []Argument{}
+	for i, value := range field.Array() {
+		argument, err := parseArgument(dir, i, value)
+		if err != nil {
+			return nil, err
+		}
+
+		path := fmt.Sprintf("arguments[%d]", i)
+		switch {
+		case argument.Name == CALL_VARIABLE:
+			return nil, newSyntaxError(dir, fmt.Sprintf("field '%s' cannot be named %q, it is reserved for the call", path, CALL_VARIABLE), value)
+		case slices.ContainsFunc(arguments, func(other Argument) bool { return other.Name == argument.Name }):
+			return nil, newSyntaxError(dir, fmt.Sprintf("field '%s' must have a unique name", path), value)
+		case len(arguments) != 0 && arguments[len(arguments)-1].IsVariadic:
+			return nil, newSyntaxError(dir, fmt.Sprintf("field '%s' cannot follow a variadic argument", path), value)
+		case len(arguments) != 0 && arguments[len(arguments)-1].IsOptional && !argument.IsOptional && !argument.IsVariadic:
+			return nil, newSyntaxError(dir, fmt.Sprintf("field '%s' must be optional, since it follows an optional argument", path), value)
+		}
+
+		arguments = append(arguments, argument)
+	}
+
+	return arguments, nil
+}
+
+func parseArgument(dir string, i int, value gjson.Result) (Argument, error) {
+	path := fmt.Sprintf("arguments[%d]", i)
+	if value.Type == gjson.String {
+		return Argument{Name: value.String(), Type: ARG_STRING}, nil
+	}
+	if !value.IsObject() {
+		return Argument{}, newSyntaxError(dir, fmt.Sprintf("field '%s' must be a string or an object", path), value)
+	}
+
+	name := value.Get("name")
+	if name.Type != gjson.String || name.String() == "" {
+		return Argument{}, newSyntaxError(dir, fmt.Sprintf("field '%s.name' must be a string", path), name)
+	}
+
+	argument := Argument{Name: name.String(), Type: ARG_STRING}
+	for _, key := range []string{"optional", "variadic"} {
+		if field := value.Get(key); field.Exists() && !field.IsBool() {
+			return Argument{}, newSyntaxError(dir, fmt.Sprintf("field '%s.%s' must be a boolean", path, key), field)
+		}
+	}
+	argument.IsOptional = value.Get("optional").Bool()
+	argument.IsVariadic = value.Get("variadic").Bool()
+
+	if err := parseConstraints(dir, path, value, &argument); err != nil {
+		return Argument{}, err
+	}
+
+	if field := value.Get("default"); field.Exists() {
+		switch {
+		case argument.Type == ARG_JSON:
+			argument.Default = field.Raw
+		case field.IsObject() || field.IsArray() || field.Type == gjson.Null:
+			return Argument{}, newSyntaxError(dir, fmt.Sprintf("field '%s.default' must be a string, number or boolean", path), field)
+		default:
+			argument.Default = field.String()
+		}
+		if err := argument.Validate(argument.Default); err != nil {
+			return Argument{}, newSyntaxError(dir, fmt.Sprintf("field '%s.default' is invalid: %s", path, err), field)
+		}
+		argument.IsOptional = true
+	}
+
+	if argument.IsVariadic && argument.IsOptional {
+		return Argument{}, newSyntaxError(dir, fmt.Sprintf("field '%s' cannot be both variadic and optional", path), value)
+	}
+	return argument, nil
+}
+
+// Parses the 'type', 'min', 'max', 'enum' and 'pattern' fields of an argument at [path]
+func parseConstraints(dir, path string, value gjson.Result, argument *Argument) error {
+	if field := value.Get("type"); field.Exists() {
+		if field.Type != gjson.String || !slices.Contains(argumentTypes, field.String()) {
+			return newSyntaxError(
+				dir,
+				fmt.Sprintf("field '%s.type' must be one of (%s)", path, strings.Join(argumentTypes, ", ")),
+				field,
+			)
+		}
+		argument.Type = field.String()
+	}
+
+	bounds := []struct {
+		key   string
+		bound **int
+	}{{"min", &argument.Min}, {"max", &argument.Max}}
+	for _, bound := range bounds {
+		key := bound.key
+		field := value.Get(key)
+		if !field.Exists() {
+			continue
+		}
+		if argument.Type != ARG_INT && argument.Type != ARG_RANGE {
+			return newSyntaxError(dir, fmt.Sprintf("field '%s.%s' can only be used with types 'int' and 'range'", path, key), field)
+		}
+		if field.Type != gjson.Number || field.Float() != float64(field.Int()) {
+			return newSyntaxError(dir, fmt.Sprintf("field '%s.%s' must be an integer", path, key), field)
+		}
+		number := int(field.Int())
+		*bound.bound = &number
+	}
+	if argument.Min != nil && argument.Max != nil && *argument.Min > *argument.Max {
+		return newSyntaxError(dir, fmt.Sprintf("field '%s.min' must not be greater than '%s.max'", path, path), value.Get("min"))
+	}
+
+	if field := value.Get("enum"); field.Exists() {
+		if !field.IsArray() || len(field.Array()) == 0 {
+			return newSyntaxError(dir, fmt.Sprintf("field '%s.enum' must be a non-empty array", path), field)
+		}
+		for i, option := range field.Array() {
+			if option.IsObject() || option.IsArray() || option.Type == gjson.Null {
+				return newSyntaxError(dir, fmt.Sprintf("field '%s.enum[%d]' must be a string, number or boolean", path, i), option)
+			}
+			argument.Enum = append(argument.Enum, option.String())
+		}
+	}
+
+	if field := value.Get("pattern"); field.Exists() {
+		if field.Type != gjson.String {
+			return newSyntaxError(dir, fmt.Sprintf("field '%s.pattern' must be a string", path), field)
+		}
+		pattern, err := regexp.Compile("^(?:" + field.String() + ")$")
+		if err != nil {
+			return newSyntaxError(dir, fmt.Sprintf("field '%s.pattern' must be a valid regular expression: %s", path, err), field)
+		}
+		argument.Pattern = pattern
+	}
+
+	return nil
+}
+
+// Returns the signature of the template, e.g. "name [count:int=1] rest..."
+func (template *Inline) Signature() string {
+	fields := make([]string, len(template.Arguments))
+	for i, argument := range template.Arguments {
+		fields[i] = argument.String()
+	}
+	return strings.Join(fields, " ")
+}
+
+// Binds the values of a call to the arguments of the template.
+// Values are either positional or "name=value" for a declared argument.
+// Returns a value per argument in the order they are declared, followed by the rest of the variadic values.
+// Every given value (and default) is validated against its argument.
+func (template *Inline) Bind(values []string) ([]string, error) {
+	named := map[string]string{}
+	positional := []string{}
+	for _, value := range values {
+		key, value_of_key, ok := code.CutNamedArg(value)
+		if !ok || !slices.ContainsFunc(template.Arguments, func(argument Argument) bool { return argument.Name == key }) {
+			positional = append(positional, value)
+			continue
+		}
+		if _, ok := named[key]; ok {
+			return nil, fmt.Errorf("argument %q is given more than once", key)
+		}
+		named[key] = value_of_key
+	}
+
+	bound := []string{}
+	for _, argument := range template.Arguments {
+		if argument.IsVariadic {
+			if _, ok := named[argument.Name]; ok {
+				return nil, fmt.Errorf("variadic argument %q cannot be given by name", argument.Name)
+			}
+			for _, value := range positional {
+				if err := argument.Validate(value); err != nil {
+					return nil, err
+				}
+			}
+			return append(bound, positional...), nil
+		}
+
+		// Arguments given by name are skipped by positional values
+		value, is_named := named[argument.Name]
+		switch {
+		case is_named:
+		case len(positional) != 0:
+			value, positional = positional[0], positional[1:]
+		case argument.IsOptional:
+			value = argument.Default
+		default:
+			return nil, fmt.Errorf("missing required argument %q", argument.Name)
+		}
+		// An optional argument without a default is left empty
+		if is_named || value != "" || !argument.IsOptional {
+			if err := argument.Validate(value); err != nil {
+				return nil, err
+			}
+		}
+		bound = append(bound, value)
+	}
+
+	if len(positional) != 0 {
+		return nil, fmt.Errorf("got %d unexpected argument(s) (%s)", len(positional), strings.Join(positional, " "))
+	}
+	return bound, nil
+}
@@ -0,0 +1,138 @@
+package templates_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/bbfh-dev/vintage/devkit/internal/drive"
+	"github.com/bbfh-dev/vintage/devkit/internal/templates"
+	"gotest.tools/assert"
+)
+
+func newInline(arguments string) (*templates.Inline, error) {
+	loader := templates.Loader{
+		FS: fstest.MapFS{"snippet.mcfunction": {Data: []byte("say %[name]")}},
+	}
+	manifest := drive.NewJsonFile([]byte(`{"type": "inline", "arguments": ` + arguments + `}`))
+	return templates.NewInline(loader, ".", manifest)
+}
+
+func TestBindArguments(t *testing.T) {
+	template, err := newInline(`["name", {"name": "count", "default": 1}, {"name": "target", "optional": true}, {"name": "rest", "variadic": true}]`)
+	assert.NilError(t, err)
+	assert.Equal(t, template.Signature(), "name [count=1] [target] rest...")
+
+	cases := []struct {
+		values []string
+		expect []string
+		err    string
+	}{
+		{[]string{"a"}, []string{"a", "1", ""}, ""},
+		{[]string{"a", "2", "@s", "x", "y"}, []string{"a", "2", "@s", "x", "y"}, ""},
+		{[]string{"count=3", "a", "@s"}, []string{"a", "3", "@s"}, ""},
+		{[]string{"a", "target=@e[tag=b]"}, []string{"a", "1", "@e[tag=b]"}, ""},
+		{[]string{"@e[tag=b]"}, []string{"@e[tag=b]", "1", ""}, ""},
+		{[]string{}, nil, `missing required argument "name"`},
+		{[]string{"name=a", "name=b"}, nil, `argument "name" is given more than once`},
+		{[]string{"a", "rest=b"}, nil, `variadic argument "rest" cannot be given by name`},
+	}
+
+	for _, test := range cases {
+		bound, err := template.Bind(test.values)
+		if test.err != "" {
+			assert.Error(t, err, test.err)
+			continue
+		}
+		assert.NilError(t, err)
+		assert.DeepEqual(t, bound, test.expect)
+	}
+
+	template, err = newInline(`["a", {"name": "b", "optional": true}]`)
+	assert.NilError(t, err)
+	_, err = template.Bind([]string{"1", "2", "3"})
+	assert.Error(t, err, "got 1 unexpected argument(s) (3)")
+}
+
+func TestInvalidArguments(t *testing.T) {
+	for _, arguments := range []string{
+		`"name"`,
+		`[1]`,
+		`["a", "a"]`,
+		`[{"name": "a", "variadic": true}, "b"]`,
+		`[{"name": "a", "optional": true}, "b"]`,
+		`[{"name": "a", "variadic": true, "default": "x"}]`,
+		`[{"name": "a", "optional": "yes"}]`,
+		`[{"default": "x"}]`,
+		`[{"name": "a", "type": "float"}]`,
+		`[{"name": "a", "min": 1}]`,
+		`[{"name": "a", "type": "int", "min": 5, "max": 1}]`,
+		`[{"name": "a", "type": "int", "default": "one"}]`,
+		`[{"name": "a", "enum": []}]`,
+		`[{"name": "a", "pattern": "("}]`,
+	} {
+		_, err := newInline(arguments)
+		assert.Assert(t, err != nil, arguments)
+	}
+}
+
+func TestTypedArguments(t *testing.T) {
+	cases := []struct {
+		argument string
+		valid    []string
+		invalid  []string
+	}{
+		{`{"name": "a", "type": "int", "min": 0, "max": 35}`, []string{"0", "35", "-0"}, []string{"abc", "1.5", "-1", "36"}},
+		{`{"name": "a", "type": "range", "min": 1}`, []string{"3", "1..5", "2..", "..7"}, []string{"..", "5..1", "a..b", "0..4", "1...5"}},
+		{`{"name": "a", "type": "resource"}`, []string{"example:test", "foo/bar", "minecraft:block/stone.json"}, []string{"Example:test", "a:b:c", "a b", ""}},
+		{`{"name": "a", "type": "objective"}`, []string{"score", "example.health", "a+b-c_d"}, []string{"a:b", "", "a b"}},
+		{`{"name": "a", "type": "selector"}`, []string{"@s", "@e[type=pig,tag=a]", "@a[scores={x=1..}]", "Steve_123", "f7c77d99-9f15-4a66-a87d-c4a51ef30d19"}, []string{"@x", "@e[type=pig", "@e]", "@s x", "ThisNameIsTooLongForMinecraft"}},
+		{`{"name": "a", "type": "nbt_path"}`, []string{"Inventory[0].id", "Item.components.\"minecraft:custom_name\"", "{Tags:[\"a b\"]}"}, []string{"", "Inventory[0", "a b", "\"unterminated"}},
+		{`{"name": "a", "type": "json"}`, []string{`{"a": 1}`, "[1, 2]", "\"text\"", "1"}, []string{"{", "text"}},
+		{`{"name": "a", "enum": ["red", "green"]}`, []string{"red", "green"}, []string{"blue"}},
+		{`{"name": "a", "pattern": "[a-z]+"}`, []string{"abc"}, []string{"ab1", "1abc"}},
+	}
+
+	for _, test := range cases {
+		template, err := newInline("[" + test.argument + "]")
+		assert.NilError(t, err, test.argument)
+		for _, value := range test.valid {
+			_, err := template.Bind([]string{value})
+			assert.NilError(t, err, "%s: %q", test.argument, value)
+		}
+		for _, value := range test.invalid {
+			_, err := template.Bind([]string{value})
+			assert.Assert(t, err != nil, "%s: %q", test.argument, value)
+		}
+	}
+
+	template, err := newInline(`[{"name": "count", "type": "int"}, {"name": "rest", "type": "int", "variadic": true}]`)
+	assert.NilError(t, err)
+	assert.Equal(t, template.Signature(), "count:int rest:int...")
+	_, err = template.Bind([]string{"1", "2", "x"})
+	assert.Error(t, err, `argument "rest" must be an integer, but got "x"`)
+	_, err = template.Bind([]string{"count=x"})
+	assert.Error(t, err, `argument "count" must be an integer, but got "x"`)
+}
+
+func TestJsonArgument(t *testing.T) {
+	loader := templates.Loader{
+		FS: fstest.MapFS{"snippet.mcfunction": {Data: []byte("say %[data.name] x%[data.count]\nsay %[fallback.name]")}},
+	}
+	manifest := drive.NewJsonFile([]byte(`{
+		"type": "inline",
+		"arguments": [
+			{"name": "data", "type": "json"},
+			{"name": "fallback", "type": "json", "default": {"name": "none"}}
+		]
+	}`))
+	template, err := templates.NewInline(loader, ".", manifest)
+	assert.NilError(t, err)
+
+	args, err := template.Bind([]string{`{"name":"apple","count":3}`})
+	assert.NilError(t, err)
+
+	output := templates.NewBuffer()
+	err = template.Call(output, templates.NewBuffer(), args, templates.CallSite{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, output.Lines, []string{"say apple x3", "say none"})
+}
@@ -2,7 +2,7 @@ package templates
 
 import (
 	"fmt"
-	"os"
+	"io/fs"
 	"path/filepath"
 	"strings"
 
@@ -17,7 +17,7 @@ type Collector struct {
 	Collector string
 }
 
-func NewCollector(root string, manifest *drive.JsonFile) (*Collector, error) {
+func NewCollector(loader Loader, root string, manifest *drive.JsonFile) (*Collector, error) {
 	template := &Collector{
 		Root:     root,
 		Patterns: []string{},
@@ -41,10 +41,18 @@ func NewCollector(root string, manifest *drive.JsonFile) (*Collector, error) {
 			)
 		}
 
+		if err := drive.ValidateGlob(arg.String()); err != nil {
+			return nil, newSyntaxError(
+				root,
+				fmt.Sprintf("field 'patterns[%d]' must be a valid pattern (%s)", i, err),
+				arg,
+			)
+		}
+
 		template.Patterns = append(template.Patterns, arg.String())
 	}
 
-	entries, err := os.ReadDir(root)
+	entries, err := fs.ReadDir(loader.FS, root)
 	if err != nil {
 		return nil, liberrors.NewIO(err, root)
 	}
@@ -1,7 +1,7 @@
 package templates
 
 import (
-	"os"
+	"io/fs"
 	"path/filepath"
 	"strings"
 
@@ -14,13 +14,13 @@ type Custom struct {
 	Program string
 }
 
-func NewCustom(root string, manifest *drive.JsonFile) (*Custom, error) {
+func NewCustom(loader Loader, root string, manifest *drive.JsonFile) (*Custom, error) {
 	template := &Custom{
 		Root:    root,
 		Program: "",
 	}
 
-	entries, err := os.ReadDir(root)
+	entries, err := fs.ReadDir(loader.FS, root)
 	if err != nil {
 		return nil, liberrors.NewIO(err, root)
 	}
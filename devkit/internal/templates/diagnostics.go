@@ -0,0 +1,68 @@
+package templates
+
+import (
+	"encoding/json"
+
+	liberrors "github.com/bbfh-dev/lib-errors"
+	liblog "github.com/bbfh-dev/lib-log"
+)
+
+const (
+	SEVERITY_ERROR   = "error"
+	SEVERITY_WARNING = "warning"
+	SEVERITY_INFO    = "info"
+)
+
+// Something the program of a template reports about a call.
+// Exec programs write them to stderr as JSON objects, one per line:
+//
+//	{"severity": "error", "message": "unknown item", "line": 2}
+//
+// The optional "line" is the offset inside of the body: 1 is its first line, 0 (the default) is the call itself.
+// Errors fail the build, other lines of stderr are logged as they are.
+type Diagnostic struct {
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Line     uint   `json:"line"`
+}
+
+// Returns false if [line] is not a diagnostic
+func ParseDiagnostic(line []byte) (Diagnostic, bool) {
+	var diagnostic Diagnostic
+	if err := json.Unmarshal(line, &diagnostic); err != nil || diagnostic.Message == "" {
+		return diagnostic, false
+	}
+
+	switch diagnostic.Severity {
+	case SEVERITY_ERROR, SEVERITY_WARNING, SEVERITY_INFO:
+		return diagnostic, true
+	}
+	return diagnostic, false
+}
+
+// Returns the first error pointing at where it happened and logs every other diagnostic
+func reportDiagnostics(diagnostics []Diagnostic, site CallSite) error {
+	var first error
+	for _, diagnostic := range diagnostics {
+		switch diagnostic.Severity {
+
+		case SEVERITY_ERROR:
+			if first != nil {
+				liblog.Error(1, "%s:%d: %s", site.Path, site.Line+diagnostic.Line, diagnostic.Message)
+				continue
+			}
+			first = &liberrors.DetailedError{
+				Label:   liberrors.ERR_EXECUTE,
+				Context: site.ErrorContext(diagnostic.Line),
+				Details: diagnostic.Message,
+			}
+
+		case SEVERITY_WARNING:
+			liblog.Warn(1, "%s:%d: %s", site.Path, site.Line+diagnostic.Line, diagnostic.Message)
+
+		default:
+			liblog.Info(1, "%s:%d: %s", site.Path, site.Line+diagnostic.Line, diagnostic.Message)
+		}
+	}
+	return first
+}
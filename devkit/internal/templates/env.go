@@ -0,0 +1,94 @@
+package templates
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bbfh-dev/vintage/devkit/internal"
+	"github.com/tidwall/gjson"
+)
+
+// Environment variables passed to the programs of templates.
+// Every program gets the project variables, inline templates also get the call variables.
+const (
+	// Absolute path to the build dir. Empty if the build is not written to disk
+	ENV_BUILD_DIR = "VINTAGE_BUILD_DIR"
+	// 'meta.name' of the project
+	ENV_NAME = "VINTAGE_NAME"
+	// 'meta.version' of the project
+	ENV_VERSION = "VINTAGE_VERSION"
+	// Targeted Minecraft version(s), e.g. "1.21.11" or "1.20.6_1.21.11"
+	ENV_MINECRAFT = "VINTAGE_MINECRAFT"
+	// Lowest and highest data pack formats of the targeted versions
+	ENV_DATA_PACK_FORMAT_MIN = "VINTAGE_DATA_PACK_FORMAT_MIN"
+	ENV_DATA_PACK_FORMAT_MAX = "VINTAGE_DATA_PACK_FORMAT_MAX"
+	// Lowest and highest resource pack formats of the targeted versions
+	ENV_RESOURCE_PACK_FORMAT_MIN = "VINTAGE_RESOURCE_PACK_FORMAT_MIN"
+	ENV_RESOURCE_PACK_FORMAT_MAX = "VINTAGE_RESOURCE_PACK_FORMAT_MAX"
+	// "true" if any targeted version reads plural data pack folder names (e.g. "functions")
+	ENV_PLURAL_FOLDERS = "VINTAGE_PLURAL_FOLDERS"
+
+	// Path of the function that calls the template, e.g. "data/example/function/test.mcfunction"
+	ENV_FUNCTION = "VINTAGE_FUNCTION"
+	// Resource location of the function, e.g. "example:test"
+	ENV_RESOURCE = "VINTAGE_RESOURCE"
+	// Namespace of the function, e.g. "example"
+	ENV_NAMESPACE = "VINTAGE_NAMESPACE"
+	// Line number of the call inside of the function
+	ENV_LINE = "VINTAGE_LINE"
+	// Identifier that is unique to the call across the project, see [CallSite.Id]
+	ENV_CALL_ID = "VINTAGE_CALL_ID"
+	// Number of the call inside of the function, see [CallSite.Counter]
+	ENV_CALL_COUNTER = "VINTAGE_CALL_COUNTER"
+)
+
+// Name of the variable that describes the call to snippets, e.g. "%[call.id]".
+// Holds the fields "id", "resource", "namespace" and "counter".
+const CALL_VARIABLE = "call"
+
+// Returns an identifier that is unique to the call across the project and stays the same between builds,
+// so that it can be used to name helper functions and scoreboard holders, e.g. "3fa81c07_2"
+func (site CallSite) Id() string {
+	hash := sha256.Sum256([]byte(filepath.ToSlash(site.Path)))
+	return hex.EncodeToString(hash[:4]) + "_" + strconv.FormatUint(uint64(site.Counter), 10)
+}
+
+// Returns the resource location and namespace of the function that makes the call, if it is known
+func (site CallSite) resource() (string, string) {
+	if site.Path == "" {
+		return "", ""
+	}
+	resource := internal.PathToResource(filepath.ToSlash(site.Path))
+	namespace, _, _ := strings.Cut(resource, ":")
+	return resource, namespace
+}
+
+// Returns the [CALL_VARIABLE] describing [site]
+func (site CallSite) Variable() gjson.Result {
+	resource, namespace := site.resource()
+	body, _ := json.Marshal(map[string]any{
+		"id":        site.Id(),
+		"resource":  resource,
+		"namespace": namespace,
+		"counter":   site.Counter,
+	})
+	return gjson.ParseBytes(body)
+}
+
+// Returns the call variables describing [site]
+func (site CallSite) Environ() []string {
+	resource, namespace := site.resource()
+
+	return []string{
+		ENV_FUNCTION + "=" + filepath.ToSlash(site.Path),
+		ENV_RESOURCE + "=" + resource,
+		ENV_NAMESPACE + "=" + namespace,
+		ENV_LINE + "=" + strconv.FormatUint(uint64(site.Line), 10),
+		ENV_CALL_ID + "=" + site.Id(),
+		ENV_CALL_COUNTER + "=" + strconv.FormatUint(uint64(site.Counter), 10),
+	}
+}
@@ -2,7 +2,8 @@ package templates
 
 import (
 	"fmt"
-	"os"
+	"io/fs"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -25,13 +26,46 @@ type Generator struct {
 	Root        string
 	Iterators   map[string]code.Rows
 	Definitions map[string]Definition
+	// How generated JSON files are merged when written to the same path
+	MergeRules drive.MergeRules
+	// Passed on to the environment of every definition, see [code.Env]
+	ForceStringify bool
 }
 
-func NewGenerator(root string, manifest *drive.JsonFile) (*Generator, error) {
+func NewGenerator(loader Loader, root string, manifest *drive.JsonFile) (*Generator, error) {
 	template := &Generator{
-		Root:        root,
-		Iterators:   map[string]code.Rows{},
-		Definitions: map[string]Definition{},
+		Root:           root,
+		Iterators:      map[string]code.Rows{},
+		Definitions:    map[string]Definition{},
+		MergeRules:     drive.DefaultMergeRules(),
+		ForceStringify: loader.ForceStringify,
+	}
+
+	if field_merge := manifest.Get("merge"); field_merge.Exists() {
+		if !field_merge.IsObject() {
+			return nil, newSyntaxError(
+				filepath.Join(root, "manifest.json"),
+				"field 'merge' must be an object of JSON paths to merge strategies",
+				field_merge,
+			)
+		}
+
+		for _, key := range field_merge.Get("@keys").Array() {
+			value := field_merge.Get(drive.EscapeKey(key.String()))
+			strategy, err := drive.ParseMergeStrategy(value.String())
+			if err != nil || value.Type != gjson.String {
+				return nil, newSyntaxError(
+					filepath.Join(root, "manifest.json"),
+					fmt.Sprintf(
+						"field 'merge.%s' must be one of %q",
+						key.String(),
+						drive.MergeStrategies,
+					),
+					value,
+				)
+			}
+			template.MergeRules.Paths[key.String()] = strategy
+		}
 	}
 
 	if field_iters := manifest.Get("iterators"); field_iters.Exists() {
@@ -68,8 +102,8 @@ func NewGenerator(root string, manifest *drive.JsonFile) (*Generator, error) {
 		}
 	}
 
-	dir := filepath.Join(root, "definitions")
-	entries, err := os.ReadDir(dir)
+	dir := path.Join(root, "definitions")
+	entries, err := fs.ReadDir(loader.FS, dir)
 	if err != nil {
 		liblog.Warn(2, "%q has no definitions", root)
 		return template, nil
@@ -80,10 +114,10 @@ func NewGenerator(root string, manifest *drive.JsonFile) (*Generator, error) {
 
 	for entry := range drive.IterateFilesOnly(entries) {
 		errs.Go(func() error {
-			path := filepath.Join(dir, entry.Name())
-			data, err := os.ReadFile(path)
+			name := path.Join(dir, entry.Name())
+			data, err := fs.ReadFile(loader.FS, name)
 			if err != nil {
-				return liberrors.NewIO(err, path)
+				return liberrors.NewIO(err, name)
 			}
 
 			file := drive.NewJsonFile(data)
@@ -91,9 +125,11 @@ func NewGenerator(root string, manifest *drive.JsonFile) (*Generator, error) {
 
 			extracted_iters := code.ExtractVariablesFrom(entry.Name())
 			if len(extracted_iters) == 0 {
+				env := code.NewEnv()
+				env.ForceStringify = template.ForceStringify
 				template.Definitions[entry.Name()] = Definition{
 					File: file,
-					Env:  code.NewEnv(),
+					Env:  env,
 				}
 			} else {
 				err := template.defineUsingIterators(entry.Name(), extracted_iters, file)
@@ -183,6 +219,7 @@ func (template *Generator) defineUsingIterators(
 
 	for {
 		env := code.NewEnv()
+		env.ForceStringify = template.ForceStringify
 
 		for i := range resolved {
 			env.Iterators[identifiers[i]] = resolved[i][indices[i]]
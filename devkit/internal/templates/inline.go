@@ -3,10 +3,14 @@ package templates
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	liberrors "github.com/bbfh-dev/lib-errors"
@@ -16,50 +20,114 @@ import (
 	"github.com/tidwall/gjson"
 )
 
-const BODY_SUBSTITUTION = "%[...]"
 const SNIPPET_FILENAME = "snippet.mcfunction"
 const INLINE_CALL_PREFIX = "#~>"
 
 type Inline struct {
-	RequiredArgs []string
-	Call         func(out Writer, in Scanner, args []string) error
+	// Arguments declared in the manifest. If nil, the rest of the call line is passed as a single argument
+	Arguments []Argument
+	Call      func(out Writer, in Scanner, args []string, site CallSite) error
+	// Passed on to the environment of the snippet, see [code.Env]
+	ForceStringify bool
+	// Project variables passed to the program, see [Loader.Env]
+	Env []string
+	// Whether the program is started once per build rather than once per call
+	IsPersistent bool
+	// Names of the sections of the body that a call can give, declared in the 'slots' field.
+	// The first one is the body given before any marker, the rest are given after "#~>> name" markers.
+	Slots []string
+	// Stops the persistent program, if there is one
+	close func() error
+	// Programs are killed once it is done, see [Loader.Context]
+	ctx context.Context
 }
 
-func NewInline(dir string, manifest *drive.JsonFile) (*Inline, error) {
-	template := &Inline{RequiredArgs: nil}
+// Where an inline template is called from
+type CallSite struct {
+	// Path of the function (relative to the project)
+	Path string
+	// Line number of the call inside of the function
+	Line uint
+	// The line of the call itself
+	Text string
+	// Number of the call among the inline template calls of the function, starting at 1.
+	// Calls made from the output of other templates are counted too.
+	Counter uint
+	// Sections of the body given after "#~>> name" markers, see [Inline.Slots]
+	Slots map[string]*Buffer
+	// Returns the context of an error [offset] lines into the body (0 is the call itself).
+	// Set by the caller, otherwise only the call is pointed at.
+	MakeErrorContext func(offset uint) liberrors.Context
+}
 
-	field_args := manifest.Get("arguments")
-	if field_args.Exists() {
-		switch {
+func (site CallSite) ErrorContext(offset uint) liberrors.Context {
+	if site.MakeErrorContext != nil {
+		return site.MakeErrorContext(offset)
+	}
+	return liberrors.FileContext{
+		Trace: []liberrors.TraceItem{
+			{
+				Name: site.Path,
+				Col:  -1,
+				Row:  int(site.Line),
+			},
+		},
+		Buffer: liberrors.Buffer{
+			FirstLine:   site.Line,
+			Buffer:      "",
+			Highlighted: site.Text,
+		},
+	}
+}
 
-		case field_args.IsArray():
-			template.RequiredArgs = []string{}
-			for _, value := range field_args.Array() {
-				if value.Type != gjson.String {
-					return nil, newSyntaxError(
-						drive.ToAbs(dir),
-						"field 'arguments' must be an array of strings",
-						value,
-					)
-				}
-				template.RequiredArgs = append(template.RequiredArgs, value.String())
-			}
+func NewInline(loader Loader, dir string, manifest *drive.JsonFile) (*Inline, error) {
+	template := &Inline{
+		Arguments:      nil,
+		ForceStringify: loader.ForceStringify,
+		Env:            loader.Env,
+		IsPersistent:   false,
+		Slots:          nil,
+		close:          nil,
+		ctx:            loader.context(),
+	}
+
+	field_persistent := manifest.Get("persistent")
+	if field_persistent.Exists() {
+		if !field_persistent.IsBool() {
+			return nil, newSyntaxError(dir, "field 'persistent' must be a boolean", field_persistent)
+		}
+		template.IsPersistent = field_persistent.Bool()
+	}
+
+	if field_args := manifest.Get("arguments"); field_args.Exists() {
+		arguments, err := parseArguments(dir, field_args)
+		if err != nil {
+			return nil, err
+		}
+		template.Arguments = arguments
+	}
 
-		default:
-			return nil, newSyntaxError(
-				drive.ToAbs(dir),
-				"field 'arguments' must be an array of strings",
-				field_args,
-			)
+	if field_slots := manifest.Get("slots"); field_slots.Exists() {
+		slots, err := parseSlots(dir, field_slots)
+		if err != nil {
+			return nil, err
 		}
+		template.Slots = slots
 	}
 
-	path := filepath.Join(dir, SNIPPET_FILENAME)
-	if _, err := os.Stat(path); err == nil {
-		return inlineTemplateUsingSnippet(template, path)
+	snippet := path.Join(dir, SNIPPET_FILENAME)
+	if body, err := fs.ReadFile(loader.FS, snippet); err == nil {
+		if template.IsPersistent {
+			return nil, &liberrors.DetailedError{
+				Label:   liberrors.ERR_VALIDATE,
+				Context: liberrors.DirContext{Path: dir},
+				Details: "field 'persistent' requires a `call*` program instead of a snippet",
+			}
+		}
+		return inlineTemplateUsingSnippet(template, snippet, body)
 	}
 
-	entries, err := os.ReadDir(dir)
+	entries, err := fs.ReadDir(loader.FS, dir)
 	if err != nil {
 		return nil, liberrors.NewIO(err, dir)
 	}
@@ -67,14 +135,27 @@ func NewInline(dir string, manifest *drive.JsonFile) (*Inline, error) {
 	for entry := range drive.IterateFilesOnly(entries) {
 		switch {
 		case strings.HasPrefix(entry.Name(), "call"):
-			path := filepath.Join(dir, entry.Name())
-			return inlineTemplateUsingExec(template, path)
+			program, err := loader.Executable(path.Join(dir, entry.Name()))
+			if err != nil {
+				return nil, liberrors.NewIO(err, dir)
+			}
+			if template.IsPersistent {
+				return inlineTemplateUsingPersistentExec(template, program)
+			}
+			if len(template.Slots) > 1 {
+				return nil, &liberrors.DetailedError{
+					Label:   liberrors.ERR_VALIDATE,
+					Context: liberrors.DirContext{Path: dir},
+					Details: "field 'slots' can only name more than one slot for a snippet or a persistent program",
+				}
+			}
+			return inlineTemplateUsingExec(template, program)
 		}
 	}
 
 	return template, &liberrors.DetailedError{
 		Label:   liberrors.ERR_VALIDATE,
-		Context: liberrors.DirContext{Path: drive.ToAbs(dir)},
+		Context: liberrors.DirContext{Path: dir},
 		Details: fmt.Sprintf(
 			"template %q contains no logic files. Must contain `*.mcfunction` or `call*`. Refer to documentation",
 			filepath.Base(dir),
@@ -83,7 +164,15 @@ func NewInline(dir string, manifest *drive.JsonFile) (*Inline, error) {
 }
 
 func (template *Inline) IsArgPassthrough() bool {
-	return template.RequiredArgs == nil
+	return template.Arguments == nil
+}
+
+// Stops the persistent program of the template. It is started again by the next call
+func (template *Inline) Close() error {
+	if template.close == nil {
+		return nil
+	}
+	return template.close()
 }
 
 func newSyntaxError(path, details string, field gjson.Result) *liberrors.DetailedError {
@@ -94,93 +183,122 @@ func newSyntaxError(path, details string, field gjson.Result) *liberrors.Detaile
 	}
 }
 
-func inlineTemplateUsingSnippet(template *Inline, path string) (*Inline, error) {
-	body, err := os.ReadFile(path)
-	if err != nil {
-		return nil, liberrors.NewIO(err, drive.ToAbs(path))
+func inlineTemplateUsingSnippet(template *Inline, path string, body []byte) (*Inline, error) {
+	lines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+	for _, match := range slotPattern.FindAllStringSubmatch(string(body), -1) {
+		if name := match[1]; name != "" && !slices.Contains(template.Slots, name) {
+			return nil, &liberrors.DetailedError{
+				Label:   liberrors.ERR_VALIDATE,
+				Context: liberrors.DirContext{Path: path},
+				Details: fmt.Sprintf("%q is not declared in the 'slots' field of the manifest", match[0]),
+			}
+		}
 	}
 
-	template.Call = func(out Writer, in Scanner, args []string) error {
+	template.Call = func(out Writer, in Scanner, args []string, site CallSite) error {
 		env := code.NewEnv()
-		for i, arg := range args {
-			env.Variables[template.RequiredArgs[i]] = code.SimpleVariable(arg)
-		}
-
-		body := string(body)
-		lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
-
-		var before string
-		var after string
-		var ok bool
-		for i, line := range lines {
-			if strings.Contains(line, BODY_SUBSTITUTION) {
-				if i > 0 {
-					before = strings.Join(lines[:i], "\n")
-				}
-				after = strings.Join(lines[i+1:], "\n")
-				ok = true
+		env.ForceStringify = template.ForceStringify
+		env.Variables[CALL_VARIABLE] = site.Variable()
+		for i, argument := range template.Arguments {
+			if argument.IsVariadic {
+				env.Variables[argument.Name] = code.SimpleVariable(strings.Join(args[i:], " "))
 				break
 			}
+			if argument.Type == ARG_JSON && args[i] != "" {
+				env.Variables[argument.Name] = gjson.Parse(args[i])
+				continue
+			}
+			env.Variables[argument.Name] = code.SimpleVariable(args[i])
 		}
 
-		if !ok {
-			before = strings.Join(lines, "\n")
-			return writeSubstituted(out, path, before, env)
-		}
-
-		if err := writeSubstituted(out, path, before, env); err != nil {
-			return err
-		}
+		body := []string{}
 		for in.Scan() {
-			out.Writeln(in.Text())
+			body = append(body, in.Text())
 		}
-		if err := writeSubstituted(out, path, after, env); err != nil {
-			return err
+
+		// Lines around the slots are substituted, the slots are written as they are
+		last := 0
+		for i, line := range lines {
+			match := slotPattern.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			if err := writeSubstituted(out, path, lines[last:i], env); err != nil {
+				return err
+			}
+			for _, line := range template.slotLines(match[1], body, site) {
+				out.Writeln(line)
+			}
+			last = i + 1
 		}
 
-		return nil
+		return writeSubstituted(out, path, lines[last:], env)
 	}
 
 	return template, nil
 }
 
 func inlineTemplateUsingExec(template *Inline, path string) (*Inline, error) {
-	template.Call = func(out Writer, in Scanner, args []string) error {
+	template.Call = func(out Writer, in Scanner, args []string, site CallSite) error {
 
-		cmd := exec.Command(path, args...)
+		cmd := exec.CommandContext(template.ctx, path, args...)
+		cmd.Env = slices.Concat(os.Environ(), template.Env, site.Environ())
 
-		var stderr bytes.Buffer
+		// Programs can flush a line in several writes, so the output is split into lines once it is complete
+		var stdout, stderr bytes.Buffer
 		cmd.Stderr = &stderr
 		cmd.Stdin = in.Reader()
-		cmd.Stdout = out
+		cmd.Stdout = &stdout
 
 		path := fmt.Sprintf("%s with [%s]", path, strings.Join(args, " "))
 
-		err := cmd.Run()
-		if err != nil {
-			return &liberrors.DetailedError{
-				Label:   liberrors.ERR_EXECUTE,
-				Context: liberrors.NewProgramContext(cmd, stderr.String()),
-				Details: err.Error(),
+		run_err := cmd.Run()
+
+		diagnostics := []Diagnostic{}
+		other_lines := []string{}
+		scanner := bufio.NewScanner(bytes.NewReader(stderr.Bytes()))
+		for scanner.Scan() {
+			if diagnostic, ok := ParseDiagnostic(scanner.Bytes()); ok {
+				diagnostics = append(diagnostics, diagnostic)
+			} else {
+				other_lines = append(other_lines, scanner.Text())
 			}
 		}
 
-		if stderr.Len() != 0 {
+		if len(other_lines) != 0 && run_err == nil {
 			liblog.Error(1, "From: %s", path)
-			scanner := bufio.NewScanner(&stderr)
-			for scanner.Scan() {
-				liblog.Error(2, "%s", scanner.Text())
+			for _, line := range other_lines {
+				liblog.Error(2, "%s", line)
 			}
 		}
 
+		// Errors reported by the program explain its failure better than the exit code
+		if err := reportDiagnostics(diagnostics, site); err != nil {
+			return err
+		}
+
+		if run_err != nil {
+			return &liberrors.DetailedError{
+				Label:   liberrors.ERR_EXECUTE,
+				Context: liberrors.NewProgramContext(cmd, strings.Join(other_lines, "\n")),
+				Details: run_err.Error(),
+			}
+		}
+
+		out.Write(stdout.Bytes())
 		return nil
 	}
 
 	return template, nil
 }
 
-func writeSubstituted(out Writer, path, in string, env code.Env) error {
-	str, err := code.SubstituteString(in, env)
+// Writes [lines] of a snippet with variables substituted. Writes nothing if there are no lines,
+// e.g. when a slot is on the first or last line of the snippet
+func writeSubstituted(out Writer, path string, lines []string, env code.Env) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	str, err := code.SubstituteString(strings.Join(lines, "\n"), env)
 	if err != nil {
 		return &liberrors.DetailedError{
 			Label:   liberrors.ERR_FORMAT,
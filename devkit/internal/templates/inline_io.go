@@ -63,6 +63,7 @@ func (buffer *Buffer) Writeln(line string) {
 	buffer.Lines = append(buffer.Lines, buffer.Indent+line)
 }
 
+// Appends every line of [data], which must not end in the middle of a line
 func (buffer *Buffer) Write(data []byte) (n int, err error) {
 	scanner := bufio.NewScanner(bytes.NewReader(data))
 	for scanner.Scan() {
@@ -0,0 +1,288 @@
+package templates
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	liberrors "github.com/bbfh-dev/lib-errors"
+	liblog "github.com/bbfh-dev/lib-log"
+)
+
+// Largest response a persistent program can reply with
+const MAX_FRAME_SIZE = 64 << 20
+
+// How long a persistent program has to exit once its stdin is closed
+const PERSISTENT_EXIT_TIMEOUT = 5 * time.Second
+
+// Sent to the stdin of a persistent program for every call, one JSON object per line
+type persistentRequest struct {
+	Id   uint64   `json:"id"`
+	Args []string `json:"args"`
+	Body []string `json:"body"`
+	// Sections of the body given after "#~>> name" markers, see [Inline.Slots]
+	Slots    map[string][]string `json:"slots,omitempty"`
+	Function string              `json:"function"`
+	Line     uint                `json:"line"`
+	// See [CALL_VARIABLE]
+	Call json.RawMessage `json:"call"`
+}
+
+// Replied by a persistent program on its stdout, one JSON object per line.
+// Replies can come in any order, they are matched to calls by their id.
+type persistentResponse struct {
+	Id          uint64       `json:"id"`
+	Output      []string     `json:"output"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+func inlineTemplateUsingPersistentExec(template *Inline, path string) (*Inline, error) {
+	program := &persistentProgram{
+		path:  path,
+		env:   template.Env,
+		ctx:   template.ctx,
+		mutex: sync.Mutex{},
+		run:   nil,
+	}
+	template.close = program.Close
+
+	template.Call = func(out Writer, in Scanner, args []string, site CallSite) error {
+		body := []string{}
+		for in.Scan() {
+			body = append(body, in.Text())
+		}
+		if args == nil {
+			args = []string{}
+		}
+		slots := map[string][]string{}
+		for name, section := range site.Slots {
+			slots[name] = section.Lines
+		}
+
+		response, err := program.Call(persistentRequest{
+			Args:     args,
+			Body:     body,
+			Slots:    slots,
+			Function: filepath.ToSlash(site.Path),
+			Line:     site.Line,
+			Call:     json.RawMessage(site.Variable().Raw),
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := reportDiagnostics(response.Diagnostics, site); err != nil {
+			return err
+		}
+
+		for _, line := range response.Output {
+			out.Writeln(line)
+		}
+		return nil
+	}
+
+	return template, nil
+}
+
+// A program that is started by the first call and handles every call until it is closed.
+// Calls can be made concurrently.
+type persistentProgram struct {
+	path string
+	// Project variables, call variables are sent along with every call instead
+	env []string
+	// The program is killed once it is done
+	ctx context.Context
+	// Guards [persistentProgram.run] and the pending calls. Never held while writing to the program,
+	// since the program can block on its stdout until its replies are dispatched, which needs it.
+	mutex sync.Mutex
+	// Set while the program is running
+	run *persistentRun
+}
+
+type persistentRun struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	// Held while writing a frame, so that frames of concurrent calls never interleave
+	write_mutex sync.Mutex
+	stderr      bytes.Buffer
+	// Id → where the reply is sent to. Guarded by [persistentProgram.mutex]
+	pending map[uint64]chan persistentResponse
+	last_id uint64
+	// Closed once the program exits, [persistentRun.err] is set before that
+	exited chan struct{}
+	err    error
+}
+
+func (program *persistentProgram) Call(request persistentRequest) (persistentResponse, error) {
+	program.mutex.Lock()
+	if program.run == nil {
+		if err := program.start(); err != nil {
+			program.mutex.Unlock()
+			return persistentResponse{}, err
+		}
+	}
+
+	run := program.run
+	run.last_id++
+	request.Id = run.last_id
+	reply := make(chan persistentResponse, 1)
+	run.pending[request.Id] = reply
+
+	program.mutex.Unlock()
+
+	frame, err := json.Marshal(request)
+	if err == nil {
+		run.write_mutex.Lock()
+		_, err = run.stdin.Write(append(frame, '\n'))
+		run.write_mutex.Unlock()
+	}
+
+	// A failed write means that the program has exited, which is reported below
+	if err != nil {
+		liblog.Debug(2, "Failed to call %q: %s", program.path, err)
+	}
+
+	select {
+	case response := <-reply:
+		return response, nil
+	case <-run.exited:
+		// The program could have replied right before exiting
+		select {
+		case response := <-reply:
+			return response, nil
+		default:
+		}
+		if run.err != nil {
+			return persistentResponse{}, run.err
+		}
+		return persistentResponse{}, &liberrors.DetailedError{
+			Label:   liberrors.ERR_EXECUTE,
+			Context: liberrors.NewProgramContext(run.cmd, run.stderr.String()),
+			Details: "program exited before replying to the call",
+		}
+	}
+}
+
+// Must be called while holding [persistentProgram.mutex]
+func (program *persistentProgram) start() error {
+	run := &persistentRun{
+		cmd:     exec.CommandContext(program.ctx, program.path),
+		pending: map[uint64]chan persistentResponse{},
+		exited:  make(chan struct{}),
+	}
+	run.cmd.Stderr = &run.stderr
+	run.cmd.Env = slices.Concat(os.Environ(), program.env)
+
+	stdin, err := run.cmd.StdinPipe()
+	if err != nil {
+		return liberrors.NewIO(err, program.path)
+	}
+	stdout, err := run.cmd.StdoutPipe()
+	if err != nil {
+		return liberrors.NewIO(err, program.path)
+	}
+	run.stdin = stdin
+
+	if err := run.cmd.Start(); err != nil {
+		return &liberrors.DetailedError{
+			Label:   liberrors.ERR_EXECUTE,
+			Context: liberrors.NewProgramContext(run.cmd, ""),
+			Details: err.Error(),
+		}
+	}
+
+	liblog.Debug(1, "Started persistent program %q", program.path)
+	program.run = run
+	go program.read(run, stdout)
+	return nil
+}
+
+// Dispatches replies to the calls until the program exits
+func (program *persistentProgram) read(run *persistentRun, stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(nil, MAX_FRAME_SIZE)
+
+	var err error
+	for scanner.Scan() {
+		var response persistentResponse
+		if err = json.Unmarshal(scanner.Bytes(), &response); err != nil {
+			err = fmt.Errorf("invalid reply %q: %w", scanner.Text(), err)
+			break
+		}
+
+		program.mutex.Lock()
+		reply, ok := run.pending[response.Id]
+		delete(run.pending, response.Id)
+		program.mutex.Unlock()
+
+		if !ok {
+			err = fmt.Errorf("reply to an unknown call (id %d)", response.Id)
+			break
+		}
+		reply <- response
+	}
+
+	if err == nil {
+		err = scanner.Err()
+	}
+	if err != nil {
+		run.cmd.Process.Kill()
+	}
+	if wait_err := run.cmd.Wait(); err == nil {
+		err = wait_err
+	}
+
+	if err != nil {
+		run.err = &liberrors.DetailedError{
+			Label:   liberrors.ERR_EXECUTE,
+			Context: liberrors.NewProgramContext(run.cmd, run.stderr.String()),
+			Details: err.Error(),
+		}
+	}
+	close(run.exited)
+}
+
+// Closes the stdin of the program and waits for it to exit
+func (program *persistentProgram) Close() error {
+	program.mutex.Lock()
+	run := program.run
+	program.run = nil
+	program.mutex.Unlock()
+
+	if run == nil {
+		return nil
+	}
+
+	run.write_mutex.Lock()
+	run.stdin.Close()
+	run.write_mutex.Unlock()
+
+	select {
+	case <-run.exited:
+	case <-time.After(PERSISTENT_EXIT_TIMEOUT):
+		liblog.Warn(1, "%q did not exit after its stdin was closed. Killing it", program.path)
+		run.cmd.Process.Kill()
+		<-run.exited
+		return nil
+	}
+
+	if run.err == nil && run.stderr.Len() != 0 {
+		liblog.Error(1, "From: %s", program.path)
+		scanner := bufio.NewScanner(&run.stderr)
+		for scanner.Scan() {
+			liblog.Error(2, "%s", scanner.Text())
+		}
+	}
+
+	liblog.Debug(1, "Stopped persistent program %q", program.path)
+	return run.err
+}
@@ -0,0 +1,160 @@
+package templates_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bbfh-dev/vintage/devkit/internal/drive"
+	"github.com/bbfh-dev/vintage/devkit/internal/templates"
+	"golang.org/x/sync/errgroup"
+	"gotest.tools/assert"
+)
+
+// Replies to every call in reverse order, once a batch of calls has arrived
+const PERSISTENT_PROGRAM = `#!/usr/bin/env python3
+import sys, json
+
+batch = []
+for frame in sys.stdin:
+    batch.append(json.loads(frame))
+    if len(batch) < 4:
+        continue
+    for call in reversed(batch):
+        output = [" ".join(call["args"]), *call["body"], f"{call['function']}:{call['line']} #{call['call']['counter']}"]
+        print(json.dumps({"id": call["id"], "output": output}), flush=True)
+    batch = []
+`
+
+func TestPersistentInline(t *testing.T) {
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "call.py"), []byte(PERSISTENT_PROGRAM), 0o755))
+
+	loader := templates.Loader{
+		FS: os.DirFS(dir),
+		Executable: func(name string) (string, error) {
+			return filepath.Join(dir, name), nil
+		},
+	}
+	manifest := drive.NewJsonFile([]byte(`{"type": "inline", "persistent": true, "arguments": ["a", "b"]}`))
+	template, err := templates.NewInline(loader, ".", manifest)
+	assert.NilError(t, err)
+	defer template.Close()
+
+	var errs errgroup.Group
+	for i := range 16 {
+		errs.Go(func() error {
+			body := templates.NewBuffer()
+			body.Writeln(fmt.Sprintf("say %d", i))
+			output := templates.NewBuffer()
+			site := templates.CallSite{Path: "data/test/function/main.mcfunction", Line: uint(i), Counter: uint(i) + 1}
+
+			if err := template.Call(output, body, []string{"x", fmt.Sprint(i)}, site); err != nil {
+				return err
+			}
+			expect := []string{
+				fmt.Sprintf("x %d", i),
+				fmt.Sprintf("say %d", i),
+				fmt.Sprintf("data/test/function/main.mcfunction:%d #%d", i, i+1),
+			}
+			if fmt.Sprint(output.Lines) != fmt.Sprint(expect) {
+				return fmt.Errorf("call %d: expected %q, but got %q", i, expect, output.Lines)
+			}
+			return nil
+		})
+	}
+	assert.NilError(t, errs.Wait())
+	assert.NilError(t, template.Close())
+}
+
+// Echoes every call back. Replies and calls are larger than pipe buffers,
+// so the program blocks on its stdout while calls are still being written to its stdin.
+const ECHO_PROGRAM = `#!/usr/bin/env python3
+import sys, json
+
+for frame in sys.stdin:
+    call = json.loads(frame)
+    print(json.dumps({"id": call["id"], "output": call["body"]}), flush=True)
+`
+
+func TestPersistentInlineLargeFrames(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	template := newPersistentInline(t, ECHO_PROGRAM, ctx)
+	defer template.Close()
+	// Kills the program first, so that a deadlocked test can still close it
+	defer cancel()
+
+	line := strings.Repeat("x", 1<<20)
+	var errs errgroup.Group
+	for range 8 {
+		errs.Go(func() error {
+			body := templates.NewBuffer()
+			body.Writeln(line)
+			output := templates.NewBuffer()
+			site := templates.CallSite{Path: "data/test/function/main.mcfunction"}
+			if err := template.Call(output, body, []string{}, site); err != nil {
+				return err
+			}
+			if len(output.Lines) != 1 || output.Lines[0] != line {
+				return fmt.Errorf("expected the body to be echoed back")
+			}
+			return nil
+		})
+	}
+
+	done := make(chan error)
+	go func() { done <- errs.Wait() }()
+	select {
+	case err := <-done:
+		assert.NilError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("calls with large frames are deadlocked")
+	}
+}
+
+// Never replies
+const SILENT_PROGRAM = `#!/bin/sh
+exec sleep 60
+`
+
+func TestPersistentInlineIsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	template := newPersistentInline(t, SILENT_PROGRAM, ctx)
+	defer template.Close()
+
+	done := make(chan error)
+	go func() {
+		site := templates.CallSite{Path: "data/test/function/main.mcfunction"}
+		done <- template.Call(templates.NewBuffer(), templates.NewBuffer(), []string{}, site)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.Assert(t, err != nil)
+	case <-time.After(10 * time.Second):
+		t.Fatal("the program is not killed once the context is cancelled")
+	}
+}
+
+func newPersistentInline(t *testing.T, program string, ctx context.Context) *templates.Inline {
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "call"), []byte(program), 0o755))
+
+	loader := templates.Loader{
+		FS: os.DirFS(dir),
+		Executable: func(name string) (string, error) {
+			return filepath.Join(dir, name), nil
+		},
+		Context: ctx,
+	}
+	manifest := drive.NewJsonFile([]byte(`{"type": "inline", "persistent": true}`))
+	template, err := templates.NewInline(loader, ".", manifest)
+	assert.NilError(t, err)
+	return template
+}
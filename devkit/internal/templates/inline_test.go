@@ -0,0 +1,120 @@
+package templates_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	liberrors "github.com/bbfh-dev/lib-errors"
+	liblog "github.com/bbfh-dev/lib-log"
+	"github.com/bbfh-dev/vintage/devkit/internal/drive"
+	"github.com/bbfh-dev/vintage/devkit/internal/templates"
+	"gotest.tools/assert"
+)
+
+const ENV_PROGRAM = `#!/bin/sh
+echo "$VINTAGE_NAME $VINTAGE_FUNCTION $VINTAGE_RESOURCE $VINTAGE_NAMESPACE $VINTAGE_LINE"
+echo "$VINTAGE_CALL_ID $VINTAGE_CALL_COUNTER"
+`
+
+func TestExecInlineEnv(t *testing.T) {
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "call.sh"), []byte(ENV_PROGRAM), 0o755))
+
+	loader := templates.Loader{
+		FS: os.DirFS(dir),
+		Executable: func(name string) (string, error) {
+			return filepath.Join(dir, name), nil
+		},
+		Env: []string{templates.ENV_NAME + "=test"},
+	}
+	template, err := templates.NewInline(loader, ".", drive.NewJsonFile([]byte(`{"type": "inline"}`)))
+	assert.NilError(t, err)
+
+	output := templates.NewBuffer()
+	site := templates.CallSite{Path: filepath.Join("data", "example", "function", "a", "b.mcfunction"), Line: 7, Counter: 2}
+	assert.NilError(t, template.Call(output, templates.NewBuffer(), []string{""}, site))
+	assert.DeepEqual(t, output.Lines, []string{
+		"test data/example/function/a/b.mcfunction example:a/b example 7",
+		site.Id() + " 2",
+	})
+}
+
+// Flushes every line in several writes, as unbuffered programs do
+const PARTIAL_WRITES_PROGRAM = `#!/bin/sh
+printf "function a"
+sleep 0.05
+printf "\n"
+printf "say "
+sleep 0.05
+printf "hi\n"
+printf "say end"
+`
+
+func TestExecInlinePartialWrites(t *testing.T) {
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "call.sh"), []byte(PARTIAL_WRITES_PROGRAM), 0o755))
+
+	loader := templates.Loader{
+		FS: os.DirFS(dir),
+		Executable: func(name string) (string, error) {
+			return filepath.Join(dir, name), nil
+		},
+	}
+	template, err := templates.NewInline(loader, ".", drive.NewJsonFile([]byte(`{"type": "inline"}`)))
+	assert.NilError(t, err)
+
+	output := templates.NewBuffer()
+	site := templates.CallSite{Path: filepath.Join("data", "example", "function", "test.mcfunction")}
+	assert.NilError(t, template.Call(output, templates.NewBuffer(), []string{""}, site))
+	assert.DeepEqual(t, output.Lines, []string{"function a", "say hi", "say end"})
+}
+
+const DIAGNOSTICS_PROGRAM = `#!/bin/sh
+echo "plain message" >&2
+echo '{"severity": "warning", "message": "deprecated"}' >&2
+echo '{"severity": "error", "message": "unknown item", "line": 2}' >&2
+echo '{"severity": "error", "message": "unknown block", "line": 4}' >&2
+echo "say hi"
+`
+
+func TestExecInlineDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "call.sh"), []byte(DIAGNOSTICS_PROGRAM), 0o755))
+
+	loader := templates.Loader{
+		FS: os.DirFS(dir),
+		Executable: func(name string) (string, error) {
+			return filepath.Join(dir, name), nil
+		},
+	}
+	template, err := templates.NewInline(loader, ".", drive.NewJsonFile([]byte(`{"type": "inline"}`)))
+	assert.NilError(t, err)
+
+	var offsets []uint
+	site := templates.CallSite{
+		Path: filepath.Join("data", "example", "function", "test.mcfunction"),
+		Line: 3,
+		MakeErrorContext: func(offset uint) liberrors.Context {
+			offsets = append(offsets, offset)
+			return liberrors.DirContext{Path: "test"}
+		},
+	}
+	var log bytes.Buffer
+	liblog.Output = &log
+	defer func() { liblog.Output = os.Stdout }()
+	err = template.Call(templates.NewBuffer(), templates.NewBuffer(), []string{""}, site)
+
+	var detailed *liberrors.DetailedError
+	assert.Assert(t, errors.As(err, &detailed))
+	assert.Equal(t, detailed.Details, "unknown item")
+	assert.DeepEqual(t, offsets, []uint{2})
+	// Only the first error is returned, the others are logged
+	assert.Assert(t, strings.Contains(log.String(), "test.mcfunction:7: unknown block"), log.String())
+
+	_, ok := templates.ParseDiagnostic([]byte(`{"severity": "fatal", "message": "?"}`))
+	assert.Assert(t, !ok)
+}
@@ -0,0 +1,27 @@
+package templates
+
+import (
+	"context"
+	"io/fs"
+)
+
+// Where templates are read from.
+// Template roots are '/' separated paths inside of [Loader.FS].
+type Loader struct {
+	FS fs.FS
+	// Returns the location of a program inside of [Loader.FS] on disk, so that it can be executed
+	Executable func(name string) (string, error)
+	// Passed on to every environment, see [code.Env]
+	ForceStringify bool
+	// Project variables ("KEY=value") passed to every program, see [ENV_BUILD_DIR]
+	Env []string
+	// Programs that are still running are killed once it is done. Defaults to [context.Background]
+	Context context.Context
+}
+
+func (loader Loader) context() context.Context {
+	if loader.Context == nil {
+		return context.Background()
+	}
+	return loader.Context
+}
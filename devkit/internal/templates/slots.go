@@ -0,0 +1,70 @@
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// A line of a snippet containing it is replaced by a section of the body of the call.
+// "%[...]" is the body given before any marker, "%[...name]" is the section given after "#~>> name".
+var slotPattern = regexp.MustCompile(`%\[\.\.\.(\w*)\]`)
+
+var slotNamePattern = regexp.MustCompile(`^\w+$`)
+
+// Starts a line that marks a section of the body of a call, see [CutSlotMarker]
+const SLOT_MARKER_PREFIX = "#~>>"
+
+// Returns the name of the slot if [line] is a marker starting a section of the body of a call, e.g.
+//
+//	#~>if score @s x matches 1
+//		say yes
+//	#~>> else
+//		say no
+func CutSlotMarker(line string) (string, bool) {
+	rest, ok := strings.CutPrefix(line, SLOT_MARKER_PREFIX)
+	if !ok {
+		return "", false
+	}
+	name := strings.TrimSpace(rest)
+	return name, slotNamePattern.MatchString(name)
+}
+
+func parseSlots(dir string, field gjson.Result) ([]string, error) {
+	if !field.IsArray() || len(field.Array()) == 0 {
+		return nil, newSyntaxError(dir, "field 'slots' must be a non-empty array", field)
+	}
+
+	slots := []string{}
+	for i, value := range field.Array() {
+		if value.Type != gjson.String || !slotNamePattern.MatchString(value.String()) {
+			return nil, newSyntaxError(dir, fmt.Sprintf("field 'slots[%d]' must be a name (letters, digits and _)", i), value)
+		}
+		if slices.Contains(slots, value.String()) {
+			return nil, newSyntaxError(dir, fmt.Sprintf("field 'slots[%d]' must be unique", i), value)
+		}
+		slots = append(slots, value.String())
+	}
+
+	return slots, nil
+}
+
+// Whether a call can give a section named [name] with a marker.
+// The first slot is always the body given before any marker.
+func (template *Inline) HasMarkedSlot(name string) bool {
+	return len(template.Slots) > 1 && slices.Contains(template.Slots[1:], name)
+}
+
+// Returns the lines of the slot [name] of a call, where [body] is the body given before any marker
+func (template *Inline) slotLines(name string, body []string, site CallSite) []string {
+	if name == "" || (len(template.Slots) != 0 && name == template.Slots[0]) {
+		return body
+	}
+	if section, ok := site.Slots[name]; ok {
+		return section.Lines
+	}
+	return nil
+}
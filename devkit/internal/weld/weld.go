@@ -0,0 +1,154 @@
+// Merges data & resource packs following the rules of Smithed Weld
+// (https://wiki.smithed.dev/weld), without depending on the external tool.
+package weld
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/bbfh-dev/vintage/devkit/internal/drive"
+	"github.com/tidwall/gjson"
+)
+
+// A single pack to be merged, with its files as '/' separated path → contents
+type Pack struct {
+	Name  string
+	Files map[string][]byte
+}
+
+// Two packs provided different values for the same file (or a JSON field within it)
+type Conflict struct {
+	Path string
+	// JSON path within the file. Empty if the whole file conflicts
+	Field     string
+	Kept      string
+	Discarded string
+}
+
+func (conflict Conflict) String() string {
+	location := conflict.Path
+	if conflict.Field != "" {
+		location += " at " + conflict.Field
+	}
+	return fmt.Sprintf("%s: kept %q over %q", location, conflict.Kept, conflict.Discarded)
+}
+
+type Result struct {
+	Files     map[string][]byte
+	Conflicts []Conflict
+}
+
+// Merges [packs] in order of increasing priority,
+// i.e. values of the last pack win over the previous ones.
+func Merge(packs ...Pack) *Result {
+	result := &Result{
+		Files:     map[string][]byte{},
+		Conflicts: []Conflict{},
+	}
+	// File path → name of the pack that last wrote it
+	origins := map[string]string{}
+
+	for _, pack := range packs {
+		names := make([]string, 0, len(pack.Files))
+		for name := range pack.Files {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		for _, name := range names {
+			body := pack.Files[name]
+			previous, ok := result.Files[name]
+			if !ok || isPackMetadata(name) {
+				result.Files[name] = body
+				origins[name] = pack.Name
+				continue
+			}
+
+			merged, conflicts := mergeFile(name, previous, body)
+			for _, field := range conflicts {
+				result.Conflicts = append(result.Conflicts, Conflict{
+					Path:      name,
+					Field:     field,
+					Kept:      pack.Name,
+					Discarded: origins[name],
+				})
+			}
+
+			result.Files[name] = merged
+			origins[name] = pack.Name
+		}
+	}
+
+	return result
+}
+
+// pack.mcmeta & pack.png always belong to the pack with the highest priority
+func isPackMetadata(name string) bool {
+	return name == "pack.mcmeta" || name == "pack.png"
+}
+
+// Returns the merged file and JSON paths of conflicting values ("" for the whole file)
+func mergeFile(name string, previous, current []byte) ([]byte, []string) {
+	if bytes.Equal(previous, current) {
+		return current, nil
+	}
+	if path.Ext(name) != ".json" && path.Ext(name) != ".mcmeta" {
+		return current, []string{""}
+	}
+	if !gjson.ValidBytes(previous) || !gjson.ValidBytes(current) {
+		return current, []string{""}
+	}
+
+	conflicts := []string{}
+	rules := rulesOf(kindOf(name))
+	rules.OnConflict = func(field string) {
+		conflicts = append(conflicts, field)
+	}
+
+	file := drive.NewJsonFile(previous)
+	file.MergeWith(drive.NewJsonFile(current), rules)
+	return file.Formatted(), conflicts
+}
+
+// Tag values and atlas sources are combined, any other value is overridden
+func rulesOf(kind Kind) drive.MergeRules {
+	rules := drive.MergeRules{
+		Arrays: drive.MERGE_OVERRIDE,
+		Paths:  map[string]drive.MergeStrategy{},
+	}
+	switch kind {
+	case KIND_TAG:
+		rules.Paths["values"] = drive.MERGE_UNION
+		rules.HonorReplace = true
+	case KIND_ATLAS:
+		rules.Paths["sources"] = drive.MERGE_UNION
+	}
+	return rules
+}
+
+type Kind int
+
+const (
+	KIND_OTHER Kind = iota
+	// data/<namespace>/tags/**.json
+	KIND_TAG
+	// assets/<namespace>/atlases/*.json
+	KIND_ATLAS
+)
+
+// Detects what kind of file [name] is, including files inside of overlays
+func kindOf(name string) Kind {
+	parts := strings.Split(name, "/")
+	for i := 0; i <= 1 && i+2 < len(parts); i++ {
+		switch {
+		case parts[i] == "data" && parts[i+2] == "tags":
+			return KIND_TAG
+		case parts[i] == "assets" && parts[i+2] == "atlases":
+			return KIND_ATLAS
+		}
+	}
+	return KIND_OTHER
+}
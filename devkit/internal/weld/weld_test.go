@@ -0,0 +1,60 @@
+package weld_test
+
+import (
+	"testing"
+
+	"github.com/bbfh-dev/vintage/devkit/internal/weld"
+	"github.com/tidwall/gjson"
+	"gotest.tools/assert"
+)
+
+func TestMergeTags(t *testing.T) {
+	result := weld.Merge(
+		weld.Pack{Name: "lib.zip", Files: map[string][]byte{
+			"data/minecraft/tags/function/load.json": []byte(`{"values": ["lib:load", "shared:load"]}`),
+			"data/ns/tags/block/a.json":              []byte(`{"values": ["stone"]}`),
+		}},
+		weld.Pack{Name: "pack.zip", Files: map[string][]byte{
+			"data/minecraft/tags/function/load.json": []byte(`{"values": [{"id": "shared:load", "required": false}, "ns:load"]}`),
+			"data/ns/tags/block/a.json":              []byte(`{"replace": true, "values": ["dirt"]}`),
+		}},
+	)
+	assert.Equal(t, len(result.Conflicts), 0)
+
+	load := gjson.ParseBytes(result.Files["data/minecraft/tags/function/load.json"])
+	assert.DeepEqual(t, load.Get("values").Value(), []any{"lib:load", "shared:load", "ns:load"})
+
+	block := gjson.ParseBytes(result.Files["data/ns/tags/block/a.json"])
+	assert.DeepEqual(t, block.Get("values").Value(), []any{"dirt"})
+}
+
+func TestMergeJson(t *testing.T) {
+	result := weld.Merge(
+		weld.Pack{Name: "lib.zip", Files: map[string][]byte{
+			"assets/ns/lang/en_us.json":       []byte(`{"a": "A", "b": "B"}`),
+			"assets/minecraft/atlases/x.json": []byte(`{"sources": [{"type": "directory", "source": "lib"}]}`),
+			"data/ns/function/a.mcfunction":   []byte("say lib"),
+			"pack.mcmeta":                     []byte(`{"pack": {"description": "lib"}}`),
+		}},
+		weld.Pack{Name: "pack.zip", Files: map[string][]byte{
+			"assets/ns/lang/en_us.json":       []byte(`{"b": "Override", "c": "C"}`),
+			"assets/minecraft/atlases/x.json": []byte(`{"sources": [{"type": "directory", "source": "pack"}]}`),
+			"data/ns/function/a.mcfunction":   []byte("say pack"),
+			"pack.mcmeta":                     []byte(`{"pack": {"description": "pack"}}`),
+		}},
+	)
+
+	lang := gjson.ParseBytes(result.Files["assets/ns/lang/en_us.json"])
+	assert.DeepEqual(t, lang.Value(), map[string]any{"a": "A", "b": "Override", "c": "C"})
+
+	atlas := gjson.ParseBytes(result.Files["assets/minecraft/atlases/x.json"])
+	assert.Equal(t, atlas.Get("sources.#").Int(), int64(2))
+
+	assert.Equal(t, string(result.Files["data/ns/function/a.mcfunction"]), "say pack")
+	assert.Equal(t, string(result.Files["pack.mcmeta"]), `{"pack": {"description": "pack"}}`)
+
+	assert.DeepEqual(t, result.Conflicts, []weld.Conflict{
+		{Path: "assets/ns/lang/en_us.json", Field: "b", Kept: "pack.zip", Discarded: "lib.zip"},
+		{Path: "data/ns/function/a.mcfunction", Kept: "pack.zip", Discarded: "lib.zip"},
+	})
+}
@@ -0,0 +1,51 @@
+package minecraft
+
+import (
+	"fmt"
+	"slices"
+)
+
+// What to do when several sources write the same output file
+type ConflictPolicy string
+
+const (
+	// Fail the build, even if several sources contribute lines to the same function
+	CONFLICTS_ERROR ConflictPolicy = "error"
+	// Report the conflict, the last writer wins. Lines contributed to the same function are concatenated
+	CONFLICTS_WARN ConflictPolicy = "warn"
+	// Merge JSON files & functions, other files behave like [CONFLICTS_WARN]
+	CONFLICTS_MERGE ConflictPolicy = "merge"
+	// The last writer silently wins, even over other contributors to the same function
+	CONFLICTS_OVERRIDE ConflictPolicy = "override"
+)
+
+var ConflictPolicies = []ConflictPolicy{
+	CONFLICTS_ERROR,
+	CONFLICTS_WARN,
+	CONFLICTS_MERGE,
+	CONFLICTS_OVERRIDE,
+}
+
+// Returns 'meta.conflicts', defaults to [CONFLICTS_WARN]
+func (mcmeta *PackMcmeta) Conflicts() ConflictPolicy {
+	if field := mcmeta.File.Get("meta.conflicts"); field.Exists() {
+		return ConflictPolicy(field.String())
+	}
+	return CONFLICTS_WARN
+}
+
+func (mcmeta *PackMcmeta) ValidateConflicts() error {
+	field := mcmeta.File.Get("meta.conflicts")
+	if !field.Exists() {
+		return nil
+	}
+	if !slices.Contains(ConflictPolicies, ConflictPolicy(field.String())) {
+		return fmt.Errorf(
+			"field 'meta.conflicts' must be one of %q, but got (%s) %q",
+			ConflictPolicies,
+			field.Type,
+			field,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package minecraft
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Which data pack folder names the targeted versions read
+type FolderLayout uint8
+
+const (
+	LAYOUT_SINGULAR FolderLayout = 1 << iota
+	LAYOUT_PLURAL
+)
+
+// Data pack format in which (24w21a, Minecraft 1.21) folders were renamed to singular
+var SingularFolderNamesFormat = DataPackFormats["24w21a"].Digits[0]
+
+// Singular data pack folder (relative to the namespace) → its name before 24w21a
+var PluralDataFolders = map[string]string{
+	"function":         "functions",
+	"loot_table":       "loot_tables",
+	"structure":        "structures",
+	"advancement":      "advancements",
+	"recipe":           "recipes",
+	"predicate":        "predicates",
+	"item_modifier":    "item_modifiers",
+	"tags/function":    "tags/functions",
+	"tags/item":        "tags/items",
+	"tags/block":       "tags/blocks",
+	"tags/entity_type": "tags/entity_types",
+	"tags/fluid":       "tags/fluids",
+	"tags/game_event":  "tags/game_events",
+}
+
+// Returns the layouts read by any version inside of the data pack format range
+func FolderLayoutOf(versions PackVersionRange) FolderLayout {
+	var layout FolderLayout
+	if versions.Min.Digits[0] < SingularFolderNamesFormat {
+		layout |= LAYOUT_PLURAL
+	}
+	if versions.Max.Digits[0] >= SingularFolderNamesFormat || layout == 0 {
+		layout |= LAYOUT_SINGULAR
+	}
+	return layout
+}
+
+func (layout FolderLayout) UsesPlural() bool {
+	return layout&LAYOUT_PLURAL != 0
+}
+
+func (layout FolderLayout) UsesSingular() bool {
+	return layout&LAYOUT_SINGULAR != 0
+}
+
+// Converts a path inside of a data pack (".../data/<namespace>/<folder>/...")
+// into every path the layout requires. Source folders may use either naming.
+// Paths that are not affected by renaming are returned as-is.
+func MapDataPath(path string, layout FolderLayout) []string {
+	fields := strings.Split(filepath.ToSlash(path), "/")
+	index := slices.Index(fields, "data")
+	if index == -1 {
+		return []string{path}
+	}
+	start := index + 2 // skip "data/<namespace>"
+
+	// Nested folders (e.g. tags/function) take priority
+	for _, depth := range []int{2, 1} {
+		if len(fields) <= start+depth {
+			continue
+		}
+
+		singular, ok := toSingularFolder(strings.Join(fields[start:start+depth], "/"))
+		if !ok {
+			continue
+		}
+
+		out := make([]string, 0, 2)
+		for _, folder := range layout.folderNames(singular) {
+			mapped := slices.Concat(fields[:start], []string{folder}, fields[start+depth:])
+			out = append(out, filepath.FromSlash(strings.Join(mapped, "/")))
+		}
+		return out
+	}
+
+	return []string{path}
+}
+
+func (layout FolderLayout) folderNames(singular string) []string {
+	names := make([]string, 0, 2)
+	if layout.UsesSingular() || layout == 0 {
+		names = append(names, singular)
+	}
+	if layout.UsesPlural() {
+		names = append(names, PluralDataFolders[singular])
+	}
+	return names
+}
+
+func toSingularFolder(folder string) (string, bool) {
+	if _, ok := PluralDataFolders[folder]; ok {
+		return folder, true
+	}
+	for singular, plural := range PluralDataFolders {
+		if plural == folder {
+			return singular, true
+		}
+	}
+	return "", false
+}
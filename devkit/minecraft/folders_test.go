@@ -0,0 +1,48 @@
+package minecraft_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bbfh-dev/vintage/devkit/minecraft"
+	"gotest.tools/assert"
+)
+
+func TestMapDataPath(t *testing.T) {
+	both := minecraft.LAYOUT_SINGULAR | minecraft.LAYOUT_PLURAL
+	cases := []struct {
+		path   string
+		layout minecraft.FolderLayout
+		expect []string
+	}{
+		{"data/ns/function/a.mcfunction", minecraft.LAYOUT_SINGULAR, []string{"data/ns/function/a.mcfunction"}},
+		{"data/ns/functions/a.mcfunction", minecraft.LAYOUT_SINGULAR, []string{"data/ns/function/a.mcfunction"}},
+		{"data/ns/function/a.mcfunction", minecraft.LAYOUT_PLURAL, []string{"data/ns/functions/a.mcfunction"}},
+		{"data/ns/tags/function/load.json", both, []string{"data/ns/tags/function/load.json", "data/ns/tags/functions/load.json"}},
+		{"data/ns/tags/enchantment/a.json", minecraft.LAYOUT_PLURAL, []string{"data/ns/tags/enchantment/a.json"}},
+		{"data/ns/worldgen/biome/a.json", minecraft.LAYOUT_PLURAL, []string{"data/ns/worldgen/biome/a.json"}},
+		{"legacy/data/ns/loot_table/a.json", minecraft.LAYOUT_PLURAL, []string{"legacy/data/ns/loot_tables/a.json"}},
+		{"data/ns/item_modifiers.json", minecraft.LAYOUT_SINGULAR, []string{"data/ns/item_modifiers.json"}},
+	}
+
+	for _, test := range cases {
+		expect := make([]string, len(test.expect))
+		for i, path := range test.expect {
+			expect[i] = filepath.FromSlash(path)
+		}
+		assert.DeepEqual(t, minecraft.MapDataPath(filepath.FromSlash(test.path), test.layout), expect)
+	}
+}
+
+func TestFolderLayoutOf(t *testing.T) {
+	layout := func(min, max string) minecraft.FolderLayout {
+		return minecraft.FolderLayoutOf(minecraft.PackVersionRange{
+			Min: minecraft.DataPackFormats[min],
+			Max: minecraft.DataPackFormats[max],
+		})
+	}
+
+	assert.Equal(t, layout("1.20.4", "1.20.6"), minecraft.LAYOUT_PLURAL)
+	assert.Equal(t, layout("1.21", "1.21.11"), minecraft.LAYOUT_SINGULAR)
+	assert.Equal(t, layout("1.20", "1.21"), minecraft.LAYOUT_SINGULAR|minecraft.LAYOUT_PLURAL)
+}
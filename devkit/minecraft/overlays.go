@@ -0,0 +1,149 @@
+package minecraft
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/tidwall/gjson"
+)
+
+// Folder (relative to the project) that contains overlay sources
+const OVERLAYS_DIR = "overlays"
+
+var overlayDirectoryPattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// Overlay declared in 'meta.overlays'
+type Overlay struct {
+	Directory string
+	// Tuple of [min_version, max_version], same as [PackMcmeta.Minecraft]
+	Minecraft [2]string
+}
+
+// Returns the overlays declared in 'meta.overlays'
+func (mcmeta *PackMcmeta) Overlays() []Overlay {
+	overlays := []Overlay{}
+	for _, field := range mcmeta.File.Get("meta.overlays").Array() {
+		overlays = append(overlays, Overlay{
+			Directory: field.Get("directory").String(),
+			Minecraft: parseMinecraftField(field.Get("minecraft")),
+		})
+	}
+	return overlays
+}
+
+func (mcmeta *PackMcmeta) ValidateOverlays() error {
+	field := mcmeta.File.Get("meta.overlays")
+	if !field.Exists() {
+		return nil
+	}
+	if !field.IsArray() {
+		return fmt.Errorf("field 'meta.overlays' must be an array, but got (%s) %q", field.Type, field)
+	}
+
+	for i, overlay := range field.Array() {
+		directory := overlay.Get("directory")
+		if directory.Type != gjson.String || !overlayDirectoryPattern.MatchString(directory.String()) {
+			return fmt.Errorf(
+				"field 'meta.overlays[%d].directory' must be a string matching %s, but got %q",
+				i,
+				overlayDirectoryPattern,
+				directory,
+			)
+		}
+
+		versions := parseMinecraftField(overlay.Get("minecraft"))
+		if versions[0] == "" {
+			return fmt.Errorf("field 'meta.overlays[%d].minecraft' must specify a version", i)
+		}
+		for _, version := range versions {
+			if version != "" && !IsVersionSupported(version) {
+				return fmt.Errorf(
+					"field 'meta.overlays[%d].minecraft' contains unknown version %q",
+					i,
+					version,
+				)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns the pack format range of the overlay
+func (overlay Overlay) Versions(formats PackFormats) PackVersionRange {
+	max_version := overlay.Minecraft[1]
+	if max_version == "" {
+		max_version = overlay.Minecraft[0]
+	}
+	return PackVersionRange{
+		Min: formats[overlay.Minecraft[0]],
+		Max: formats[max_version],
+	}
+}
+
+// Appends overlays to 'overlays.entries' of the in-memory file
+func (mcmeta *PackMcmeta) SaveOverlays(overlays []Overlay, formats PackFormats) error {
+	entries := []any{}
+	for _, entry := range mcmeta.File.Get("overlays.entries").Array() {
+		entries = append(entries, entry.Value())
+	}
+
+	for _, overlay := range overlays {
+		versions := overlay.Versions(formats)
+		entry := map[string]any{"directory": overlay.Directory}
+
+		switch versions.Max.Flag {
+
+		case USES_MIN_MAX_FORMAT:
+			entry["min_format"] = versions.Min.Digits
+			entry["max_format"] = versions.Max.Digits
+			// Older versions only understand "formats"
+			if versions.Min.Flag != USES_MIN_MAX_FORMAT {
+				entry["formats"] = map[string]any{
+					"min_inclusive": versions.Min.Value(),
+					"max_inclusive": versions.Max.Value(),
+				}
+			}
+
+		case USES_SUPPORTED_FORMATS:
+			entry["formats"] = map[string]any{
+				"min_inclusive": versions.Min.Value(),
+				"max_inclusive": versions.Max.Value(),
+			}
+
+		default:
+			return fmt.Errorf(
+				"overlay %q targets version %d, which does not support overlays",
+				overlay.Directory,
+				versions.Max.Digits,
+			)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if len(entries) != 0 {
+		mcmeta.File.Set("overlays.entries", entries)
+	}
+	return nil
+}
+
+func parseMinecraftField(field gjson.Result) [2]string {
+	if !field.Exists() {
+		return [2]string{}
+	}
+
+	if field.Type == gjson.String {
+		return [2]string{field.String()}
+	}
+
+	out := [2]string{}
+	if field := field.Get("min"); field.Exists() {
+		out[0] = field.String()
+	}
+	if field := field.Get("max"); field.Exists() {
+		out[1] = field.String()
+	}
+
+	return out
+}
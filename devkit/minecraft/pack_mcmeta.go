@@ -34,9 +34,28 @@ func (mcmeta *PackMcmeta) Validate() error {
 		mcmeta.File.ExpectField("meta.minecraft", gjson.String, gjson.JSON),
 		mcmeta.File.ExpectField("meta.version", gjson.String),
 		mcmeta.ValidateVersion(),
+		mcmeta.ValidateOverlays(),
+		mcmeta.ValidateTargets(),
+		mcmeta.ValidatePackSections(),
+		mcmeta.ValidateOutput(),
+		mcmeta.ValidateConflicts(),
 	)
 }
 
+func (mcmeta *PackMcmeta) ValidateOutput() error {
+	field := mcmeta.Output()
+	if !field.Exists() {
+		return nil
+	}
+	if field.Type != gjson.String || strings.TrimSpace(field.String()) == "" {
+		return fmt.Errorf("field 'meta.output' must be a non-empty string, but got (%s) %q", field.Type, field)
+	}
+	if strings.ContainsAny(field.String(), `/\`) {
+		return fmt.Errorf("field 'meta.output' must be a file name, but got %q", field)
+	}
+	return nil
+}
+
 func (mcmeta *PackMcmeta) ValidateVersion() error {
 	if mcmeta.Minecraft()[0] != "" {
 		return nil
@@ -109,26 +128,14 @@ func (mcmeta *PackMcmeta) Name() gjson.Result {
 	return mcmeta.File.Get("meta.name")
 }
 
+// Returns the naming pattern of exported files (without extension)
+func (mcmeta *PackMcmeta) Output() gjson.Result {
+	return mcmeta.File.Get("meta.output")
+}
+
 // Returns a tuple of [min_version, max_version]
 func (mcmeta *PackMcmeta) Minecraft() [2]string {
-	field := mcmeta.File.Get("meta.minecraft")
-	if !field.Exists() {
-		return [2]string{}
-	}
-
-	if field.Type == gjson.String {
-		return [2]string{field.String()}
-	}
-
-	out := [2]string{}
-	if field := field.Get("min"); field.Exists() {
-		out[0] = field.String()
-	}
-	if field := field.Get("max"); field.Exists() {
-		out[1] = field.String()
-	}
-
-	return out
+	return parseMinecraftField(mcmeta.File.Get("meta.minecraft"))
 }
 
 func (mcmeta *PackMcmeta) MinecraftVersionOverride(name string) (PackVersion, bool) {
@@ -0,0 +1,47 @@
+package minecraft
+
+import (
+	"fmt"
+
+	"github.com/bbfh-dev/vintage/devkit/internal/drive"
+)
+
+// Top-level pack.mcmeta sections that only one kind of pack reads
+var packOnlySections = map[string][]string{
+	"data_pack":     {"features"},
+	"resource_pack": {"language"},
+}
+
+// Merges 'meta.<dir>' (either "data_pack" or "resource_pack") over the in-memory file
+// and removes the sections that only the other kind of pack reads.
+func (mcmeta *PackMcmeta) ApplyPackSection(dir string) {
+	if section := mcmeta.File.Get("meta." + dir); section.IsObject() {
+		// Only objects are merged, as the section mirrors the structure of pack.mcmeta
+		section_file := drive.NewJsonFile([]byte(section.Raw))
+		mcmeta.File.MergeWith(section_file, drive.MergeRules{Arrays: drive.MERGE_OVERRIDE})
+	}
+
+	for other_dir, sections := range packOnlySections {
+		if other_dir == dir {
+			continue
+		}
+		for _, section := range sections {
+			mcmeta.File.Delete(section)
+		}
+	}
+}
+
+func (mcmeta *PackMcmeta) ValidatePackSections() error {
+	for dir := range packOnlySections {
+		field := mcmeta.File.Get("meta." + dir)
+		if field.Exists() && !field.IsObject() {
+			return fmt.Errorf(
+				"field 'meta.%s' must be an object, but got (%s) %q",
+				dir,
+				field.Type,
+				field,
+			)
+		}
+	}
+	return nil
+}
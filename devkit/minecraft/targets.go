@@ -0,0 +1,68 @@
+package minecraft
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Returns the versions declared in 'meta.targets'.
+// Every target is a tuple of [min_version, max_version], same as [PackMcmeta.Minecraft]
+func (mcmeta *PackMcmeta) Targets() [][2]string {
+	targets := [][2]string{}
+	for _, field := range mcmeta.File.Get("meta.targets").Array() {
+		targets = append(targets, parseMinecraftField(field))
+	}
+	return targets
+}
+
+func (mcmeta *PackMcmeta) ValidateTargets() error {
+	field := mcmeta.File.Get("meta.targets")
+	if !field.Exists() {
+		return nil
+	}
+	if !field.IsArray() {
+		return fmt.Errorf("field 'meta.targets' must be an array, but got (%s) %q", field.Type, field)
+	}
+
+	for i, target := range mcmeta.Targets() {
+		if err := ValidateTarget(target); err != nil {
+			return fmt.Errorf("field 'meta.targets[%d]' %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func ValidateTarget(target [2]string) error {
+	if target[0] == "" {
+		return fmt.Errorf("must specify a version")
+	}
+	for _, version := range target {
+		if version != "" && !IsVersionSupported(version) {
+			return fmt.Errorf("contains unknown version %q", version)
+		}
+	}
+	return nil
+}
+
+// Returns a copy of the mcmeta that targets only the provided version range
+func (mcmeta *PackMcmeta) WithTarget(target [2]string) *PackMcmeta {
+	clone := mcmeta.Clone()
+	if target[1] == "" {
+		clone.File.Set("meta.minecraft", target[0])
+	} else {
+		clone.File.Set("meta.minecraft", map[string]string{
+			"min": target[0],
+			"max": target[1],
+		})
+	}
+	return clone
+}
+
+// Formats the target to be used as a directory name
+func TargetName(target [2]string) string {
+	if target[1] == "" || target[1] == target[0] {
+		return target[0]
+	}
+	return strings.Join(target[:], "_")
+}
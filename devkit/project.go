@@ -1,22 +1,57 @@
 package devkit
 
 import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
 	liblog "github.com/bbfh-dev/lib-log"
-	"github.com/bbfh-dev/vintage/cli"
 	"github.com/bbfh-dev/vintage/devkit/internal/autolibs"
+	"github.com/bbfh-dev/vintage/devkit/internal/cache"
 	"github.com/bbfh-dev/vintage/devkit/internal/mcfunc"
 	"github.com/bbfh-dev/vintage/devkit/internal/pipeline"
 	"github.com/bbfh-dev/vintage/devkit/internal/templates"
 	"github.com/bbfh-dev/vintage/devkit/minecraft"
+	"github.com/bbfh-dev/vintage/devkit/vfs"
 )
 
 type Project struct {
-	Meta     *minecraft.PackMcmeta
-	BuildDir string
+	// Input tree of the project
+	Source fs.FS
+	// Where [Project.Source] is located on disk, if it is.
+	// Required to manage automatic libraries, templates run from it otherwise.
+	Dir string
+	// Build directory
+	Output  vfs.Sink
+	Meta    *minecraft.PackMcmeta
+	Options Options
+
+	// Pack dir → where the pack is written
+	packs map[string]vfs.Sink
+	// Holds the programs of templates when [Project.Dir] is unset
+	programsDir   string
+	programsMutex sync.Mutex
+
+	// Cancels the build between stages, set by [Project.Build]
+	ctx context.Context
+	// Data pack folder layout read by the targeted versions
+	folderLayout minecraft.FolderLayout
+	registry     *mcfunc.Registry
+	// Results of every generator template, ordered by template name
+	generatorResults []*GeneratorResult
 
-	extraFilesToCopy []string
-	isDataCached     bool
-	isAssetsCached   bool
+	// Pack dir → path of the icon to use
+	packIcons map[string]string
+	// Pack dir → name (without extension) of the exported file
+	outputNames    map[string]string
+	isDataCached   bool
+	isAssetsCached bool
+	cache          *cache.Manifest
+	inputs         map[string]*cache.Entry
+	plans          map[string]*packPlan
+	outputs        *outputTable
 
 	generatorTemplates map[string]*templates.Generator
 	collectorTemplates map[string]*templates.Collector
@@ -24,27 +59,70 @@ type Project struct {
 	customTemplates    map[string]*templates.Custom
 
 	libraries []*autolibs.Library
+	// Overlay directory → data pack folder layout its versions read
+	overlayLayouts map[string]minecraft.FolderLayout
 }
 
-func New(mcmeta *minecraft.PackMcmeta) *Project {
+func New(source fs.FS, output vfs.Sink, mcmeta *minecraft.PackMcmeta, options Options) *Project {
+	overlay_layouts := map[string]minecraft.FolderLayout{}
+	for _, overlay := range mcmeta.Overlays() {
+		versions := overlay.Versions(minecraft.DataPackFormats)
+		overlay_layouts[overlay.Directory] = minecraft.FolderLayoutOf(versions)
+	}
+
+	// Zipped packs never touch the build directory until they are exported
+	packs := map[string]vfs.Sink{}
+	for _, pack_dir := range []string{"data_pack", "resource_pack"} {
+		if options.Zip {
+			packs[pack_dir] = vfs.NewZipSink(vfs.ZipOptions{
+				Deterministic: options.Reproducible,
+				Level:         options.CompressionLevel,
+			})
+		} else {
+			packs[pack_dir] = vfs.Sub(output, pack_dir)
+		}
+	}
+
 	return &Project{
-		Meta:     mcmeta,
-		BuildDir: cli.Build.Options.Output,
+		Source:  source,
+		Dir:     "",
+		Output:  output,
+		Meta:    mcmeta,
+		Options: options,
+
+		packs:       packs,
+		programsDir: "",
+
+		ctx:              context.Background(),
+		folderLayout:     getFolderLayout(mcmeta),
+		registry:         mcfunc.NewRegistry(),
+		generatorResults: nil,
 
-		extraFilesToCopy: []string{},
-		isDataCached:     false,
-		isAssetsCached:   false,
+		packIcons:      map[string]string{},
+		outputNames:    map[string]string{},
+		isDataCached:   false,
+		isAssetsCached: false,
+		cache:          cache.New(),
+		inputs:         map[string]*cache.Entry{},
+		plans:          map[string]*packPlan{},
+		outputs:        newOutputTable(),
 
 		generatorTemplates: map[string]*templates.Generator{},
 		collectorTemplates: map[string]*templates.Collector{},
 		inlineTemplates:    map[string]*templates.Inline{},
 		customTemplates:    map[string]*templates.Custom{},
 
-		libraries: []*autolibs.Library{},
+		libraries:      []*autolibs.Library{},
+		overlayLayouts: overlay_layouts,
 	}
 }
 
-func (project *Project) Build() error {
+func (project *Project) Build(ctx context.Context) error {
+	project.ctx = ctx
+	defer project.removePrograms()
+	// Only needed if the build fails, otherwise the templates are already closed
+	defer project.CloseTemplates()
+
 	liblog.Info(
 		0,
 		"Building %s for Minecraft %s",
@@ -52,9 +130,12 @@ func (project *Project) Build() error {
 		project.Meta.MinecraftFormatted(),
 	)
 
-	return pipeline.New(
+	return pipeline.WithContext(
+		ctx,
 		project.LogHeader("Preparing..."),
 		project.DetectPackIcon,
+		project.ResolveOutputNames,
+		project.LoadCache,
 		project.CheckIfCached(&project.isDataCached, FOLDER_DATA),
 		project.CheckIfCached(&project.isAssetsCached, FOLDER_ASSETS),
 		project.LoadTemplates,
@@ -63,20 +144,84 @@ func (project *Project) Build() error {
 		pipeline.Async(project.GenerateFromTemplates),
 		pipeline.Async(
 			project.writeMcfunctions,
-			project.writeGeneratedJsonFiles,
+			project.writeGeneratedFiles,
 		),
+		project.CloseTemplates,
 		project.RunCustomTemplates,
-		// project.CollectFromTemplates,
+		project.CollectFromTemplates,
 		project.LoadAutoLibs,
 		project.ManageAutoLibs,
-		pipeline.If[pipeline.Task](cli.Build.Options.Zip).
+		pipeline.If[pipeline.Task](project.Options.Zip).
 			Then(project.ZipPacks),
-		pipeline.If[pipeline.Task](cli.Build.Options.Zip).
+		pipeline.If[pipeline.Task](project.Options.Zip).
 			Then(project.WeldPacks),
+		project.SaveCache,
 	)
 }
 
-func Reset() {
-	mcfunc.UsedNamespaces = map[string]byte{}
-	mcfunc.Registry = map[string][]string{}
+// Returns the location of [path] (relative to the project) on disk,
+// or [path] itself if the project is not on disk. Used in errors and by external programs.
+func (project *Project) pathOf(path string) string {
+	if project.Dir == "" {
+		return path
+	}
+	return filepath.Join(project.Dir, path)
+}
+
+// Returns the location on disk of the program [name] (inside of [Project.Source]).
+// Programs are extracted into a temporary directory if the project is not on disk.
+func (project *Project) executable(name string) (string, error) {
+	if project.Dir != "" {
+		return filepath.Abs(project.pathOf(name))
+	}
+
+	project.programsMutex.Lock()
+	defer project.programsMutex.Unlock()
+
+	if project.programsDir == "" {
+		dir, err := os.MkdirTemp("", "vintage-programs-")
+		if err != nil {
+			return "", err
+		}
+		project.programsDir = dir
+	}
+
+	body, err := fs.ReadFile(project.Source, name)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(project.programsDir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, body, 0o755)
+}
+
+func (project *Project) removePrograms() {
+	if project.programsDir != "" {
+		os.RemoveAll(project.programsDir)
+		project.programsDir = ""
+	}
+}
+
+// Input helpers, paths are relative to the project
+
+func (project *Project) stat(path string) (fs.FileInfo, error) {
+	return fs.Stat(project.Source, filepath.ToSlash(path))
+}
+
+func (project *Project) readDir(path string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(project.Source, filepath.ToSlash(path))
+}
+
+func (project *Project) readFile(path string) ([]byte, error) {
+	return fs.ReadFile(project.Source, filepath.ToSlash(path))
+}
+
+// Same as [fs.WalkDir], but paths use the OS separator
+func (project *Project) walkDir(root string, fn fs.WalkDirFunc) error {
+	return fs.WalkDir(project.Source, filepath.ToSlash(root), func(path string, entry fs.DirEntry, err error) error {
+		return fn(filepath.FromSlash(path), entry, err)
+	})
 }
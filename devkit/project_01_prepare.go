@@ -2,15 +2,21 @@ package devkit
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 
 	liberrors "github.com/bbfh-dev/lib-errors"
 	liblog "github.com/bbfh-dev/lib-log"
-	"github.com/bbfh-dev/vintage/cli"
+	libparsex "github.com/bbfh-dev/lib-parsex/v3"
+	"github.com/bbfh-dev/vintage/devkit/internal/cache"
+	"github.com/bbfh-dev/vintage/devkit/internal/code"
 	"github.com/bbfh-dev/vintage/devkit/internal/drive"
 	"github.com/bbfh-dev/vintage/devkit/internal/pipeline"
 	"github.com/bbfh-dev/vintage/devkit/internal/templates"
+	"github.com/bbfh-dev/vintage/devkit/minecraft"
 	"github.com/tidwall/gjson"
 )
 
@@ -27,71 +33,208 @@ func (project *Project) LogHeader(header string) pipeline.Task {
 	}
 }
 
+// Looks for "data/pack.png" and "assets/pack.png", falling back to "pack.png"
 func (project *Project) DetectPackIcon() error {
-	_, err := os.Stat("pack.png")
-	if os.IsNotExist(err) {
+	for _, folder := range []string{FOLDER_DATA, FOLDER_ASSETS} {
+		for _, path := range []string{filepath.Join(folder, "pack.png"), "pack.png"} {
+			if _, err := project.stat(path); err == nil {
+				liblog.Info(1, "Found %q for %s", path, getPackDir(folder))
+				project.packIcons[getPackDir(folder)] = path
+				break
+			}
+		}
+	}
+
+	if len(project.packIcons) == 0 {
 		liblog.Warn(1, "No pack icon found")
-		return nil
+	}
+	return nil
+}
+
+// Used when 'meta.output' is unset.
+// The kind is dropped when the project only has a data or a resource pack.
+const (
+	DEFAULT_OUTPUT        = "%[name]_%[kind]_v%[version]"
+	DEFAULT_SINGLE_OUTPUT = "%[name]_v%[version]"
+)
+
+// Resolves the names (without extension) that exported packs are saved as
+func (project *Project) ResolveOutputNames() error {
+	pack_dirs := []string{}
+	for _, folder := range []string{FOLDER_DATA, FOLDER_ASSETS} {
+		if _, err := project.stat(folder); err == nil {
+			pack_dirs = append(pack_dirs, getPackDir(folder))
+		}
+	}
+
+	pattern := project.Meta.Output().String()
+	if pattern == "" {
+		pattern = DEFAULT_OUTPUT
+		if len(pack_dirs) == 1 {
+			pattern = DEFAULT_SINGLE_OUTPUT
+		}
 	}
 
-	liblog.Info(1, "Found 'pack.png'")
-	project.extraFilesToCopy = append(project.extraFilesToCopy, "pack.png")
+	env := code.NewEnv()
+	env.ForceStringify = project.Options.ForceStringify
+	env.Variables["name"] = project.Meta.Name()
+	env.Variables["version"] = code.SimpleVariable(project.Meta.VersionFormatted())
+	env.Variables["minecraft"] = code.SimpleVariable(minecraft.TargetName(project.Meta.Minecraft()))
+
+	for _, pack_dir := range []string{"data_pack", "resource_pack"} {
+		env.Variables["kind"] = code.SimpleVariable(getZipLabel(pack_dir))
+		name, err := code.SubstituteString(pattern, env)
+		if err != nil {
+			return &liberrors.DetailedError{
+				Label:   liberrors.ERR_FORMAT,
+				Context: liberrors.DirContext{Path: project.pathOf("pack.mcmeta")},
+				Details: "meta.output: " + err.Error(),
+			}
+		}
+		project.outputNames[pack_dir] = name
+	}
+
+	if len(pack_dirs) > 1 && project.outputNames["data_pack"] == project.outputNames["resource_pack"] {
+		return &liberrors.DetailedError{
+			Label:   liberrors.ERR_VALIDATE,
+			Context: liberrors.DirContext{Path: project.pathOf("pack.mcmeta")},
+			Details: fmt.Sprintf(
+				"meta.output: both packs resolve to %q. Use %%[kind] to tell them apart",
+				project.outputNames["data_pack"],
+			),
+		}
+	}
+
+	liblog.Debug(1, "Resolved output names: %v", project.outputNames)
 	return nil
 }
 
+func (project *Project) LoadCache() error {
+	project.cache = cache.Load(project.Output, cache.FILENAME)
+
+	// The cache is rewritten once the build succeeds, so that
+	// a failed build never leaves behind a cache of partial output.
+	return liberrors.NewIO(project.Output.RemoveAll(cache.FILENAME), cache.FILENAME)
+}
+
 func (project *Project) CheckIfCached(value *bool, folder string) pipeline.Task {
-	if cli.Build.Options.Force {
+	if project.Options.Force {
 		return nil
 	}
 
-	var libs_folder, zip_path string
-	switch folder {
-	case FOLDER_DATA:
-		libs_folder, zip_path = "data_packs", project.getZipPath("DP")
-	case FOLDER_ASSETS:
-		libs_folder, zip_path = "resource_packs", project.getZipPath("RP")
-	}
-
+	pack_dir := getPackDir(folder)
 	return func() error {
-		if _, err := os.Stat(folder); os.IsNotExist(err) {
+		if _, err := project.stat(folder); os.IsNotExist(err) {
 			*value = true
-			liblog.Warn(
-				1,
-				"%q cannot be created, there is no %q folder",
-				filepath.Base(zip_path),
-				folder,
-			)
+			// Single-pack projects share the output name, so the pack dir is reported instead
+			liblog.Warn(1, "%q cannot be created, there is no %q folder", pack_dir, folder)
 			return nil
 		}
 
-		timestamp := drive.GetMostRecentIn(
-			folder,
-			filepath.Join("libs", libs_folder),
-			"templates",
-		)
+		output := pack_dir
+		if project.Options.Zip {
+			output = project.getZipName(pack_dir)
+		}
+		if _, err := fs.Stat(project.Output, output); err != nil {
+			liblog.Debug(1, "%q is missing. Caching is impossible", output)
+			return nil
+		}
 
-		info, err := os.Stat(zip_path)
-		if err != nil {
-			liblog.Warn(1, "%q is missing. Caching is impossible", filepath.Base(zip_path))
+		previous, ok := project.cache.Packs[pack_dir]
+		if !ok {
+			liblog.Debug(1, "%q has never been cached", output)
 			return nil
 		}
-		zip_timestamp := info.ModTime()
 
-		if timestamp.Sub(zip_timestamp) < 0 {
+		entry, err := project.hashInputs(folder)
+		if err != nil {
+			return err
+		}
+		project.inputs[pack_dir] = entry
+
+		if entry.Matches(previous) {
 			*value = true
-			liblog.Cached(1, "%q is already up-to-date", filepath.Base(zip_path))
+			liblog.Cached(1, "%q is already up-to-date", output)
 		}
 
 		return nil
 	}
 }
 
+// Records the inputs of every pack that was built (or cached) into the build directory
+func (project *Project) SaveCache() error {
+	manifest := cache.New()
+
+	for _, folder := range []string{FOLDER_DATA, FOLDER_ASSETS} {
+		if _, err := project.stat(folder); os.IsNotExist(err) {
+			continue
+		}
+
+		entry, err := project.hashInputs(folder)
+		if err != nil {
+			return err
+		}
+
+		pack_dir := getPackDir(folder)
+		if plan, ok := project.plans[pack_dir]; ok {
+			entry.Outputs = plan.Graph.Export()
+		} else if previous, ok := project.cache.Packs[pack_dir]; ok {
+			entry.Outputs = previous.Outputs
+		}
+		if pack_dir == "data_pack" {
+			entry.Namespaces = project.namespacesBySource()
+		}
+		manifest.Packs[pack_dir] = entry
+	}
+
+	liblog.Debug(0, "Saving build cache to %q", cache.FILENAME)
+	return manifest.Save(project.Output, cache.FILENAME)
+}
+
+// Hashes everything that affects the output of the pack built from [folder]
+func (project *Project) hashInputs(folder string) (*cache.Entry, error) {
+	libs_folder := "data_packs"
+	if folder == FOLDER_ASSETS {
+		libs_folder = "resource_packs"
+	}
+
+	return cache.NewEntry(
+		project.Source,
+		[]string{
+			"pack.mcmeta",
+			"pack.png",
+			folder,
+			minecraft.OVERLAYS_DIR,
+			"templates",
+			filepath.Join("libs", libs_folder),
+		},
+		map[string]string{
+			"vintage":         libparsex.GetVersion(),
+			"minecraft":       project.Meta.MinecraftFormatted(),
+			"output":          project.Options.Output,
+			"output_name":     project.outputNames[getPackDir(folder)],
+			"zip":             strconv.FormatBool(project.Options.Zip),
+			"force_stringify": strconv.FormatBool(project.Options.ForceStringify),
+			"keep_meta":       strconv.FormatBool(project.Options.KeepMeta),
+			"reproducible":    strconv.FormatBool(project.Options.Reproducible),
+			"compression":     strconv.Itoa(project.Options.CompressionLevel),
+		},
+	)
+}
+
+func getPackDir(folder string) string {
+	if filepath.Base(folder) == FOLDER_ASSETS {
+		return "resource_pack"
+	}
+	return "data_pack"
+}
+
 func (project *Project) LoadTemplates() error {
 	if project.isDataCached && project.isAssetsCached {
 		return nil
 	}
 
-	_, err := os.Stat("templates")
+	_, err := project.stat("templates")
 	if os.IsNotExist(err) {
 		liblog.Debug(1, "No templates found")
 		return nil
@@ -99,33 +242,41 @@ func (project *Project) LoadTemplates() error {
 
 	liblog.Info(1, "Loading templates")
 
-	entries, err := os.ReadDir("templates")
+	entries, err := project.readDir("templates")
 	if err != nil {
-		return liberrors.NewIO(err, drive.ToAbs("templates"))
+		return liberrors.NewIO(err, project.pathOf("templates"))
+	}
+
+	loader := templates.Loader{
+		FS:             project.Source,
+		Executable:     project.executable,
+		ForceStringify: project.Options.ForceStringify,
+		Env:            project.templateEnv(project.buildDirOnDisk()),
+		Context:        project.ctx,
 	}
 
 	for entry := range drive.IterateDirsOnly(entries) {
-		path := filepath.Join("templates", entry.Name(), "manifest.json")
-		manifest_data, err := os.ReadFile(path)
+		dir := path.Join("templates", entry.Name())
+		manifest_path := path.Join(dir, "manifest.json")
+		manifest_data, err := fs.ReadFile(project.Source, manifest_path)
 		if err != nil {
-			return liberrors.NewIO(err, drive.ToAbs(path))
+			return liberrors.NewIO(err, project.pathOf(manifest_path))
 		}
 		manifest := drive.NewJsonFile(manifest_data)
 
 		if err := manifest.ExpectField("type", gjson.String); err != nil {
 			return &liberrors.DetailedError{
 				Label:   liberrors.ERR_VALIDATE,
-				Context: liberrors.DirContext{Path: path},
+				Context: liberrors.DirContext{Path: project.pathOf(manifest_path)},
 				Details: err.Error(),
 			}
 		}
 
-		dir := filepath.Join("templates", entry.Name())
 		template_type := manifest.Get("type").String()
 		switch template_type {
 
 		case "inline":
-			template, derr := templates.NewInline(dir, manifest)
+			template, derr := templates.NewInline(loader, dir, manifest)
 			if derr != nil {
 				return derr
 			}
@@ -133,7 +284,7 @@ func (project *Project) LoadTemplates() error {
 			liblog.Debug(2, "Loaded inline:%s", entry.Name())
 
 		case "generator":
-			template, derr := templates.NewGenerator(dir, manifest)
+			template, derr := templates.NewGenerator(loader, dir, manifest)
 			if derr != nil {
 				return derr
 			}
@@ -141,7 +292,7 @@ func (project *Project) LoadTemplates() error {
 			liblog.Debug(2, "Loaded generator:%s", entry.Name())
 
 		case "collector":
-			template, derr := templates.NewCollector(dir, manifest)
+			template, derr := templates.NewCollector(loader, dir, manifest)
 			if derr != nil {
 				return derr
 			}
@@ -149,7 +300,7 @@ func (project *Project) LoadTemplates() error {
 			liblog.Debug(2, "Loaded collector:%s", entry.Name())
 
 		case "custom":
-			template, derr := templates.NewCustom(dir, manifest)
+			template, derr := templates.NewCustom(loader, dir, manifest)
 			if derr != nil {
 				return derr
 			}
@@ -159,11 +310,17 @@ func (project *Project) LoadTemplates() error {
 		default:
 			return &liberrors.DetailedError{
 				Label:   liberrors.ERR_SYNTAX,
-				Context: liberrors.DirContext{Path: path},
+				Context: liberrors.DirContext{Path: project.pathOf(manifest_path)},
 				Details: fmt.Sprintf("unknown template type %q", template_type),
 			}
 		}
 	}
 
+	// Collectors read both packs, so neither of them can stay cached while the other one is built
+	if len(project.collectorTemplates) != 0 && project.isDataCached != project.isAssetsCached {
+		liblog.Debug(1, "Collector templates read both packs, building both of them")
+		project.isDataCached, project.isAssetsCached = false, false
+	}
+
 	return nil
 }
@@ -1,61 +1,53 @@
 package devkit
 
 import (
-	"os"
+	"io/fs"
 	"path/filepath"
 	"strings"
 
 	liberrors "github.com/bbfh-dev/lib-errors"
 	liblog "github.com/bbfh-dev/lib-log"
-	"github.com/bbfh-dev/vintage/cli"
+	"github.com/bbfh-dev/vintage/devkit/internal"
+	"github.com/bbfh-dev/vintage/devkit/internal/code"
 	"github.com/bbfh-dev/vintage/devkit/internal/drive"
 	"github.com/bbfh-dev/vintage/devkit/internal/pipeline"
 	"github.com/bbfh-dev/vintage/devkit/minecraft"
-	cp "github.com/otiai10/copy"
+	"github.com/tidwall/gjson"
 	"golang.org/x/sync/errgroup"
 )
 
-func (project *Project) clearDir(path string) pipeline.Task {
+func (project *Project) clearPack(pack_dir string) pipeline.Task {
 	return func() error {
-		err := os.RemoveAll(path)
-		if err != nil {
-			return liberrors.NewIO(err, project.BuildDir)
-		}
-
-		err = os.MkdirAll(path, os.ModePerm)
-		if err != nil {
-			return liberrors.NewIO(err, project.BuildDir)
-		}
-
-		return nil
+		return liberrors.NewIO(project.packs[pack_dir].RemoveAll("."), pack_dir)
 	}
 }
 
-func (project *Project) copyPackDirs(
-	folder, out_folder string,
-	folders *[]string,
-) pipeline.AsyncTask {
+func (project *Project) copyPackDirs(folder string, folders *[]string) pipeline.AsyncTask {
 	return func(errs *errgroup.Group) error {
-		data_entries, err := os.ReadDir(folder)
+		plan := project.plans[getPackDir(folder)]
+
+		data_entries, err := project.readDir(folder)
 		if err != nil {
-			return liberrors.NewIO(err, drive.ToAbs(folder))
+			return liberrors.NewIO(err, project.pathOf(folder))
 		}
 
 		for data_entry := range drive.IterateDirsOnly(data_entries) {
 			path := filepath.Join(folder, data_entry.Name())
-			folder_entries, err := os.ReadDir(path)
+			folder_entries, err := project.readDir(path)
 			if err != nil {
-				return liberrors.NewIO(err, path)
+				return liberrors.NewIO(err, project.pathOf(path))
 			}
 
 			for _, folder_entry := range folder_entries {
 				path := filepath.Join(folder, data_entry.Name(), folder_entry.Name())
 
 				if !folder_entry.IsDir() {
-					liblog.Debug(1, "Copying file %q", path)
-					errs.Go(func() error {
-						return cp.Copy(path, filepath.Join(out_folder, path))
-					})
+					if plan.ShouldBuild(path) {
+						liblog.Debug(1, "Copying file %q", path)
+						if err := project.copyFile(errs, plan, path); err != nil {
+							return err
+						}
+					}
 					continue
 				}
 
@@ -65,19 +57,21 @@ func (project *Project) copyPackDirs(
 						*folders = append(*folders, path)
 					}
 				default:
-					liblog.Debug(1, "Copying directory %q", path)
-					errs.Go(func() error {
-						return cp.Copy(path, filepath.Join(out_folder, path))
-					})
-					if cli.UsesPluralFolderNames {
-						name := folder_entry.Name()
-						if !strings.HasSuffix(name, "s") {
-							name = name + "s"
+					if plan.IsFull {
+						liblog.Debug(1, "Copying directory %q", path)
+					}
+					err := project.walkDir(path, func(file string, entry fs.DirEntry, err error) error {
+						if err != nil || entry.IsDir() || !plan.ShouldBuild(file) {
+							return err
 						}
-						new_path := filepath.Join(folder, data_entry.Name(), name)
-						errs.Go(func() error {
-							return cp.Copy(path, filepath.Join(out_folder, new_path))
-						})
+						if !plan.IsFull {
+							liblog.Debug(1, "Copying file %q", file)
+						}
+
+						return project.copyFile(errs, plan, file)
+					})
+					if err != nil {
+						return err
 					}
 				}
 			}
@@ -87,31 +81,156 @@ func (project *Project) copyPackDirs(
 	}
 }
 
-func (project *Project) copyExtraFiles(dir string) pipeline.Task {
-	return func() error {
-		for _, file := range project.extraFilesToCopy {
-			liblog.Debug(1, "Copying extra %q", file)
-			path := filepath.Join(dir, file)
-			err := cp.Copy(file, path)
-			if err != nil {
-				return liberrors.NewIO(err, path)
+// Copies [source] into every path of the pack it belongs to and records it in the plan
+func (project *Project) copyFile(errs *errgroup.Group, plan *packPlan, source string) error {
+	for _, output := range project.outputPathsOf(plan.Dir, source) {
+		plan.Graph.Add(filepath.ToSlash(source), filepath.ToSlash(output))
+
+		output := filepath.Join(plan.Dir, output)
+		if err := project.claimOutput(output, project.fileSource(source)); err != nil {
+			return err
+		}
+		errs.Go(func() error {
+			return project.writeOutput(output, project.fileSource(source))
+		})
+	}
+	return nil
+}
+
+// Returns every path (relative to the pack) that [path] must be written to,
+// according to the folder layout read by the targeted versions.
+func (project *Project) outputPathsOf(pack_dir, path string) []string {
+	path = toOutputPath(path)
+	if pack_dir != "data_pack" {
+		return []string{path}
+	}
+	return minecraft.MapDataPath(path, project.layoutOf(path))
+}
+
+// Returns the folder layout of the overlay that [path] (relative to the pack) belongs to
+func (project *Project) layoutOf(path string) minecraft.FolderLayout {
+	if layout, ok := project.overlayLayouts[internal.PathPrefix(path)]; ok {
+		return layout
+	}
+	return project.folderLayout
+}
+
+// Copies every overlay that has a [folder] (data or assets) into the pack
+func (project *Project) copyOverlayDirs(folder string, folders *[]string) pipeline.AsyncTask {
+	return func(errs *errgroup.Group) error {
+		for _, overlay := range project.overlaysWith(folder) {
+			path := filepath.Join(minecraft.OVERLAYS_DIR, overlay.Directory, folder)
+			liblog.Debug(1, "Copying overlay %q", overlay.Directory)
+			if err := project.copyPackDirs(path, folders)(errs); err != nil {
+				return err
 			}
 		}
 		return nil
 	}
 }
 
+// Returns the overlays that contain [folder] (data or assets)
+func (project *Project) overlaysWith(folder string) []minecraft.Overlay {
+	overlays := []minecraft.Overlay{}
+	for _, overlay := range project.Meta.Overlays() {
+		path := filepath.Join(minecraft.OVERLAYS_DIR, overlay.Directory, folder)
+		if _, err := project.stat(path); err == nil {
+			overlays = append(overlays, overlay)
+		}
+	}
+	return overlays
+}
+
+// Converts a path relative to the project into a path relative to the pack,
+// i.e. "overlays/<name>/data/..." becomes "<name>/data/...".
+func toOutputPath(path string) string {
+	if rest, ok := strings.CutPrefix(filepath.ToSlash(path), minecraft.OVERLAYS_DIR+"/"); ok {
+		return filepath.FromSlash(rest)
+	}
+	return path
+}
+
+func (project *Project) copyPackIcon(pack_dir string) pipeline.Task {
+	return func() error {
+		icon, ok := project.packIcons[pack_dir]
+		if !ok {
+			return nil
+		}
+
+		liblog.Debug(1, "Copying icon %q", icon)
+		body, err := project.readFile(icon)
+		if err != nil {
+			return liberrors.NewIO(err, project.pathOf(icon))
+		}
+		return liberrors.NewIO(project.packs[pack_dir].WriteFile("pack.png", body), pack_dir)
+	}
+}
+
 func (project *Project) createPackMcmeta(dir, name string, ft minecraft.PackFormats) pipeline.Task {
 	return func() error {
 		liblog.Info(1, "Exporting pack.mcmeta for %s", dir)
 		mcmeta := project.Meta.Clone()
+		mcmeta.ApplyPackSection(dir)
 		mcmeta.FillVersion(name, ft)
 		if err := mcmeta.SaveVersion(); err != nil {
 			liblog.Warn(1, "%s", err.Error())
 		}
 
-		path := filepath.Join(project.BuildDir, dir, "pack.mcmeta")
-		err := os.WriteFile(path, mcmeta.File.Formatted(), os.ModePerm)
-		return liberrors.NewIO(err, path)
+		folder := FOLDER_DATA
+		if dir == "resource_pack" {
+			folder = FOLDER_ASSETS
+		}
+		if err := mcmeta.SaveOverlays(project.overlaysWith(folder), ft); err != nil {
+			return &liberrors.DetailedError{
+				Label:   liberrors.ERR_VALIDATE,
+				Context: liberrors.DirContext{Path: project.pathOf("pack.mcmeta")},
+				Details: err.Error(),
+			}
+		}
+
+		if err := project.substituteDescription(mcmeta); err != nil {
+			return &liberrors.DetailedError{
+				Label:   liberrors.ERR_FORMAT,
+				Context: liberrors.DirContext{Path: project.pathOf("pack.mcmeta")},
+				Details: "pack.description: " + err.Error(),
+			}
+		}
+
+		if !project.Options.KeepMeta {
+			mcmeta.File.Delete("meta")
+		}
+
+		err := project.packs[dir].WriteFile("pack.mcmeta", mcmeta.File.Formatted())
+		return liberrors.NewIO(err, filepath.Join(dir, "pack.mcmeta"))
+	}
+}
+
+// Substitutes '%[meta.*]' placeholders inside of 'pack.description'.
+// Unlike the file, '%[meta.minecraft]' is always a formatted string.
+func (project *Project) substituteDescription(mcmeta *minecraft.PackMcmeta) error {
+	meta := drive.NewJsonFile([]byte(project.Meta.File.Get("meta").Raw))
+	meta.Set("minecraft", project.Meta.MinecraftFormatted())
+
+	env := code.NewEnv()
+	env.ForceStringify = project.Options.ForceStringify
+	env.Variables["meta"] = meta.Get("@this")
+
+	field := mcmeta.File.Get("pack.description")
+	switch {
+
+	case field.Type == gjson.String:
+		description, err := code.SubstituteString(field.String(), env)
+		if err != nil {
+			return err
+		}
+		mcmeta.File.Set("pack.description", description)
+
+	case field.IsArray():
+		return code.SubstituteArray(mcmeta.File, env, "pack.description")
+
+	case field.IsObject():
+		return code.SubstituteObject(mcmeta.File, env, "pack.description")
 	}
+
+	return nil
 }
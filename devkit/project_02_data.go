@@ -3,8 +3,10 @@ package devkit
 import (
 	"bufio"
 	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	liberrors "github.com/bbfh-dev/lib-errors"
@@ -20,35 +22,42 @@ func (project *Project) GenerateDataPack() error {
 		return nil
 	}
 
-	_, err := os.Stat(FOLDER_DATA)
+	_, err := project.stat(FOLDER_DATA)
 	if os.IsNotExist(err) {
 		liblog.Debug(0, "No data pack found")
 		return nil
 	}
 
 	liblog.Info(0, "Creating a Data Pack")
-	path := filepath.Join(project.BuildDir, "data_pack")
+	plan, err := project.planPack(FOLDER_DATA)
+	if err != nil {
+		return err
+	}
+	project.plans["data_pack"] = plan
 
 	var funcFoldersToParse = []string{}
 
 	return pipeline.New(
-		project.clearDir(path),
+		pipeline.If[pipeline.Task](plan.IsFull).
+			Then(project.clearPack("data_pack")),
 		pipeline.Async(
-			project.copyPackDirs(FOLDER_DATA, path, &funcFoldersToParse),
+			project.copyPackDirs(FOLDER_DATA, &funcFoldersToParse),
+			project.copyOverlayDirs(FOLDER_DATA, &funcFoldersToParse),
 		),
 		pipeline.Async(
 			project.parseMcFunctions(&funcFoldersToParse),
 		),
-		project.copyExtraFiles(path),
+		project.copyPackIcon("data_pack"),
 		project.createPackMcmeta("data_pack", "data", minecraft.DataPackFormats),
 	)
 }
 
 func (project *Project) parseMcFunctions(folders *[]string) pipeline.AsyncTask {
 	return func(errs *errgroup.Group) error {
+		plan := project.plans["data_pack"]
 		for _, path := range *folders {
-			filepath.WalkDir(path, func(path string, entry fs.DirEntry, err error) error {
-				if err != nil || entry.IsDir() {
+			project.walkDir(path, func(path string, entry fs.DirEntry, err error) error {
+				if err != nil || entry.IsDir() || !plan.ShouldBuild(path) {
 					return err
 				}
 				errs.Go(func() error {
@@ -63,14 +72,14 @@ func (project *Project) parseMcFunctions(folders *[]string) pipeline.AsyncTask {
 }
 
 func (project *Project) parseFunction(path string) error {
-	file, err := os.OpenFile(path, os.O_RDONLY, os.ModePerm)
+	file, err := project.Source.Open(filepath.ToSlash(path))
 	if err != nil {
-		return liberrors.NewIO(err, path)
+		return liberrors.NewIO(err, project.pathOf(path))
 	}
 	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
-	fn := mcfunc.New(path, scanner, project.inlineTemplates)
+	fn := mcfunc.New(path, scanner, project.inlineTemplates, project.registry)
 	proc := mcfunc.NewProcessor(fn)
 
 	if err := proc.Build(); err != nil {
@@ -81,23 +90,60 @@ func (project *Project) parseFunction(path string) error {
 }
 
 func (project *Project) writeMcfunctions(errs *errgroup.Group) error {
-	for path, lines := range mcfunc.Registry {
-		errs.Go(func() error {
-			path := filepath.Join(project.BuildDir, "data_pack", path)
+	plan, has_plan := project.plans["data_pack"]
+
+	for path, lines_of := range project.registry.Functions {
+		sources := slices.Sorted(maps.Keys(lines_of))
+
+		for _, output := range project.outputPathsOf("data_pack", path) {
+			if has_plan {
+				for _, source := range sources {
+					plan.Graph.Add(filepath.ToSlash(source), filepath.ToSlash(output))
+				}
+			}
+
+			output := filepath.Join("data_pack", output)
 
-			err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
-			if err != nil {
-				return liberrors.NewIO(err, path)
+			// Functions that several sources contribute lines to are concatenated
+			// unless 'meta.conflicts' asks to reject or override them
+			if !project.isFunctionConflict() {
+				bodies := make([]string, len(sources))
+				for i, source := range sources {
+					bodies[i] = strings.Join(lines_of[source], "\n")
+				}
+				source := bytesSource(strings.Join(sources, " + "), []byte(strings.Join(bodies, "\n")))
+				if err := project.claimOutput(output, source); err != nil {
+					return err
+				}
+				errs.Go(func() error {
+					return project.writeOutput(output, source)
+				})
+				continue
 			}
 
-			err = os.WriteFile(path, []byte(strings.Join(lines, "\n")), os.ModePerm)
-			if err != nil {
-				return liberrors.NewIO(err, path)
+			for _, source := range sources {
+				body := []byte(strings.Join(lines_of[source], "\n"))
+				if err := project.claimOutput(output, bytesSource(source, body)); err != nil {
+					return err
+				}
 			}
 
-			return nil
-		})
+			last := sources[len(sources)-1]
+			body := []byte(strings.Join(lines_of[last], "\n"))
+			errs.Go(func() error {
+				return project.writeOutput(output, bytesSource(last, body))
+			})
+		}
 	}
 
 	return nil
 }
+
+// Whether sources contributing lines to the same function conflict rather than being concatenated
+func (project *Project) isFunctionConflict() bool {
+	switch project.Meta.Conflicts() {
+	case minecraft.CONFLICTS_ERROR, minecraft.CONFLICTS_OVERRIDE:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package devkit
+
+import (
+	"maps"
+	"path"
+	"path/filepath"
+	"slices"
+
+	liberrors "github.com/bbfh-dev/lib-errors"
+	liblog "github.com/bbfh-dev/lib-log"
+	"github.com/bbfh-dev/vintage/devkit/internal/cache"
+	"github.com/bbfh-dev/vintage/devkit/minecraft"
+	"github.com/bbfh-dev/vintage/devkit/vfs"
+)
+
+// Describes which sources of a pack need to be processed
+type packPlan struct {
+	// Either "data_pack" or "resource_pack"
+	Dir string
+	// Source file → files it emitted into the pack
+	Graph *cache.Graph
+	// Source file → namespaces used by its functions, for sources that are not rebuilt
+	Namespaces map[string][]string
+	// Whether the pack is built from scratch
+	IsFull  bool
+	sources map[string]bool
+}
+
+func (plan *packPlan) ShouldBuild(source string) bool {
+	return plan.IsFull || plan.sources[filepath.ToSlash(source)]
+}
+
+// Compares current inputs with the cached ones to decide what to rebuild.
+// Outputs of sources that changed or were removed are deleted.
+func (project *Project) planPack(folder string) (*packPlan, error) {
+	pack_dir := getPackDir(folder)
+	full := &packPlan{
+		Dir:        pack_dir,
+		Graph:      cache.NewGraph(nil),
+		Namespaces: map[string][]string{},
+		IsFull:     true,
+		sources:    nil,
+	}
+
+	current, previous := project.inputs[pack_dir], project.cache.Packs[pack_dir]
+	if current == nil || previous == nil || previous.Outputs == nil {
+		return full, nil
+	}
+	pack := project.packs[pack_dir]
+	if vfs.IsEmpty(pack, ".") {
+		return full, nil
+	}
+	if current.ChangedOutside(previous, folder, minecraft.OVERLAYS_DIR, "libs") {
+		liblog.Debug(1, "Global inputs have changed, rebuilding %q from scratch", pack_dir)
+		return full, nil
+	}
+
+	plan := &packPlan{
+		Dir:        pack_dir,
+		Graph:      cache.NewGraph(previous.Outputs),
+		Namespaces: maps.Clone(previous.Namespaces),
+		IsFull:     false,
+		sources:    map[string]bool{},
+	}
+	if plan.Namespaces == nil {
+		plan.Namespaces = map[string][]string{}
+	}
+
+	changed, removed := current.ChangedIn(previous, folder, minecraft.OVERLAYS_DIR)
+	queue := append(changed, removed...)
+	// Collectors read the whole build, so the files they merged into are always rebuilt
+	for _, template := range project.collectorTemplates {
+		queue = append(queue, filepath.ToSlash(template.Root))
+	}
+	for _, source := range queue {
+		plan.sources[source] = true
+	}
+
+	// Sources that emit the same file must be rebuilt together
+	for len(queue) != 0 {
+		source := queue[0]
+		queue = queue[1:]
+
+		for _, output := range plan.Graph.OutputsOf(source) {
+			for _, other := range plan.Graph.SourcesOf(output) {
+				if plan.sources[other] {
+					continue
+				}
+				if _, ok := current.Files[other]; !ok {
+					liblog.Debug(
+						1,
+						"%q shares %q with %q, rebuilding %q from scratch",
+						source,
+						output,
+						other,
+						pack_dir,
+					)
+					return full, nil
+				}
+				plan.sources[other] = true
+				queue = append(queue, other)
+			}
+		}
+	}
+
+	for source := range plan.sources {
+		delete(plan.Namespaces, source)
+		for _, output := range plan.Graph.Remove(source) {
+			liblog.Debug(1, "Removing stale %q", output)
+			if err := pack.RemoveAll(output); err != nil {
+				return nil, liberrors.NewIO(err, path.Join(pack_dir, output))
+			}
+			removeEmptyParents(pack, output)
+		}
+	}
+
+	liblog.Info(1, "Rebuilding %d changed source(s)", len(plan.sources))
+	return plan, nil
+}
+
+// Returns source file → namespaces used by its functions,
+// including the sources of the data pack that were not rebuilt
+func (project *Project) namespacesBySource() map[string][]string {
+	namespaces := map[string][]string{}
+	if plan, ok := project.plans["data_pack"]; ok {
+		maps.Copy(namespaces, plan.Namespaces)
+	} else if previous, ok := project.cache.Packs["data_pack"]; ok {
+		// None of the functions were parsed if the data pack is cached
+		maps.Copy(namespaces, previous.Namespaces)
+	}
+	for source, used := range project.registry.UsedNamespaces {
+		namespaces[filepath.ToSlash(source)] = slices.Sorted(maps.Keys(used))
+	}
+	return namespaces
+}
+
+// Whether any pack that is going to be built must be built from scratch
+func (project *Project) hasFullRebuild() bool {
+	for _, plan := range project.plans {
+		if plan.IsFull {
+			return true
+		}
+	}
+	return false
+}
+
+// Removes the parent directories of [name] that became empty
+func removeEmptyParents(sink vfs.Sink, name string) {
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if !vfs.IsEmpty(sink, dir) || sink.RemoveAll(dir) != nil {
+			return
+		}
+	}
+}
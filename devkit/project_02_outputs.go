@@ -0,0 +1,186 @@
+package devkit
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	liberrors "github.com/bbfh-dev/lib-errors"
+	liblog "github.com/bbfh-dev/lib-log"
+	"github.com/bbfh-dev/vintage/devkit/internal/drive"
+	"github.com/bbfh-dev/vintage/devkit/minecraft"
+)
+
+// Something that writes an output file: a static file, a template, a function, etc.
+type outputSource struct {
+	Name string
+	// Returns the contents the source writes into the output
+	Read func() ([]byte, error)
+}
+
+// Returns a source that reads [path] (relative to the project)
+func (project *Project) fileSource(path string) outputSource {
+	return outputSource{
+		Name: path,
+		Read: func() ([]byte, error) { return project.readFile(path) },
+	}
+}
+
+func bytesSource(name string, body []byte) outputSource {
+	return outputSource{
+		Name: name,
+		Read: func() ([]byte, error) { return body, nil },
+	}
+}
+
+// Tracks the provenance of every output file written during a build
+type outputTable struct {
+	mutex sync.Mutex
+	// Output (relative to the build dir) → sources in the order they claimed it
+	sources map[string][]outputSource
+	// Output → lock held while writing it
+	locks map[string]*sync.Mutex
+}
+
+func newOutputTable() *outputTable {
+	return &outputTable{
+		sources: map[string][]outputSource{},
+		locks:   map[string]*sync.Mutex{},
+	}
+}
+
+// Records [source] as a writer of [output] (relative to the build dir)
+// and reports a conflict according to 'meta.conflicts' if it was already written by another source.
+func (project *Project) claimOutput(output string, source outputSource) error {
+	table := project.outputs
+	table.mutex.Lock()
+	sources := table.sources[output]
+	table.sources[output] = append(sources, source)
+	if _, ok := table.locks[output]; !ok {
+		table.locks[output] = &sync.Mutex{}
+	}
+	table.mutex.Unlock()
+
+	if len(sources) == 0 || sources[len(sources)-1].Name == source.Name {
+		return nil
+	}
+	previous := sources[len(sources)-1].Name
+
+	switch project.Meta.Conflicts() {
+	case minecraft.CONFLICTS_ERROR:
+		return &liberrors.DetailedError{
+			Label:   liberrors.ERR_VALIDATE,
+			Context: liberrors.DirContext{Path: output},
+			Details: fmt.Sprintf(
+				"conflicting writes from %q and %q. "+
+					"Set 'meta.conflicts' to \"warn\", \"merge\" or \"override\" to allow it",
+				previous,
+				source.Name,
+			),
+		}
+	case minecraft.CONFLICTS_WARN:
+		liblog.Warn(1, "%q is written by both %q and %q. Using the latter", output, previous, source.Name)
+	case minecraft.CONFLICTS_MERGE:
+		if !isMergeable(output) {
+			liblog.Warn(1, "%q is written by both %q and %q, but cannot be merged. Using the latter", output, previous, source.Name)
+		} else {
+			liblog.Debug(1, "Merging %q from %q and %q", output, previous, source.Name)
+		}
+	}
+
+	return nil
+}
+
+// Writes [output] (relative to the build dir) unless a source claimed it after [source].
+// Merges the contents of every source that claimed it if 'meta.conflicts' is "merge".
+func (project *Project) writeOutput(output string, source outputSource) error {
+	table := project.outputs
+	table.mutex.Lock()
+	lock := table.locks[output]
+	table.mutex.Unlock()
+
+	if lock == nil {
+		return fmt.Errorf("(Assertion fail) %q is written without being claimed", output)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+
+	// Sources are read while holding the lock, otherwise a stale write could
+	// overwrite the one of a source that claimed the output in the meantime
+	table.mutex.Lock()
+	sources := table.sources[output]
+	table.mutex.Unlock()
+
+	if sources[len(sources)-1].Name != source.Name {
+		return nil
+	}
+
+	body, err := project.contentsOf(output, sources)
+	if err != nil {
+		return err
+	}
+
+	pack_dir, name, _ := strings.Cut(filepath.ToSlash(output), "/")
+	return liberrors.NewIO(project.packs[pack_dir].WriteFile(name, body), output)
+}
+
+// Returns the contents of the last source, or of all sources merged together
+func (project *Project) contentsOf(output string, sources []outputSource) ([]byte, error) {
+	last := sources[len(sources)-1]
+	if project.Meta.Conflicts() != minecraft.CONFLICTS_MERGE || !isMergeable(output) {
+		return readSource(last)
+	}
+
+	// The same source can claim an output several times (e.g. for every definition),
+	// in which case its latest claim is used
+	unique := []outputSource{}
+	indices := map[string]int{}
+	for _, source := range sources {
+		if i, ok := indices[source.Name]; ok {
+			unique[i] = source
+			continue
+		}
+		indices[source.Name] = len(unique)
+		unique = append(unique, source)
+	}
+	if len(unique) == 1 {
+		return readSource(last)
+	}
+
+	contents := make([][]byte, len(unique))
+	for i, source := range unique {
+		body, err := readSource(source)
+		if err != nil {
+			return nil, err
+		}
+		contents[i] = body
+	}
+
+	if filepath.Ext(output) == ".mcfunction" {
+		return bytes.Join(contents, []byte("\n")), nil
+	}
+
+	merged := drive.NewJsonFile(contents[0])
+	for _, body := range contents[1:] {
+		merged.MergeWith(drive.NewJsonFile(body), drive.DefaultMergeRules())
+	}
+	return merged.Formatted(), nil
+}
+
+func readSource(source outputSource) ([]byte, error) {
+	body, err := source.Read()
+	if err != nil {
+		return nil, liberrors.NewIO(err, source.Name)
+	}
+	return body, nil
+}
+
+func isMergeable(output string) bool {
+	switch filepath.Ext(output) {
+	case ".json", ".mcmeta", ".mcfunction":
+		return true
+	}
+	return false
+}
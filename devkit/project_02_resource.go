@@ -2,7 +2,6 @@ package devkit
 
 import (
 	"os"
-	"path/filepath"
 
 	liblog "github.com/bbfh-dev/lib-log"
 	"github.com/bbfh-dev/vintage/devkit/internal/pipeline"
@@ -14,21 +13,27 @@ func (project *Project) GenerateResourcePack() error {
 		return nil
 	}
 
-	_, err := os.Stat(FOLDER_ASSETS)
+	_, err := project.stat(FOLDER_ASSETS)
 	if os.IsNotExist(err) {
 		liblog.Debug(0, "No resource pack found")
 		return nil
 	}
 
 	liblog.Info(0, "Creating a Resource Pack")
-	path := filepath.Join(project.BuildDir, "resource_pack")
+	plan, err := project.planPack(FOLDER_ASSETS)
+	if err != nil {
+		return err
+	}
+	project.plans["resource_pack"] = plan
 
 	return pipeline.New(
-		project.clearDir(path),
+		pipeline.If[pipeline.Task](plan.IsFull).
+			Then(project.clearPack("resource_pack")),
 		pipeline.Async(
-			project.copyPackDirs(FOLDER_ASSETS, path, nil),
+			project.copyPackDirs(FOLDER_ASSETS, nil),
+			project.copyOverlayDirs(FOLDER_ASSETS, nil),
 		),
-		project.copyExtraFiles(path),
+		project.copyPackIcon("resource_pack"),
 		project.createPackMcmeta("resource_pack", "resources", minecraft.ResourcePackFormats),
 	)
 }
@@ -3,179 +3,410 @@ package devkit
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io/fs"
+	"maps"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 
 	liberrors "github.com/bbfh-dev/lib-errors"
 	liblog "github.com/bbfh-dev/lib-log"
+	"github.com/bbfh-dev/vintage/devkit/internal/cache"
 	"github.com/bbfh-dev/vintage/devkit/internal/code"
 	"github.com/bbfh-dev/vintage/devkit/internal/drive"
 	"github.com/bbfh-dev/vintage/devkit/internal/mcfunc"
+	"github.com/bbfh-dev/vintage/devkit/internal/templates"
+	"github.com/bbfh-dev/vintage/devkit/minecraft"
+	"github.com/bbfh-dev/vintage/devkit/vfs"
+	"github.com/tidwall/gjson"
 	"golang.org/x/sync/errgroup"
 )
 
-var GeneratorResults []map[string]*drive.JsonFile
+// Files generated by a single generator template.
+// Paths are relative to the build dir and are mapped into the folder layout when written.
+type GeneratorResult struct {
+	Root  string
+	Json  map[string]*drive.JsonFile
+	Raw   map[string][]byte
+	Rules drive.MergeRules
+}
 
 func (project *Project) GenerateFromTemplates(errs *errgroup.Group) error {
-	// TODO: this code needs refactoring
 	if project.isDataCached && project.isAssetsCached {
 		return nil
 	}
 
+	// Generators depend only on templates, which force a full rebuild when changed
+	if !project.hasFullRebuild() {
+		liblog.Debug(0, "Skipping generator templates, they are unchanged")
+		return nil
+	}
+
 	liblog.Info(0, "Generating from %d template(s)", len(project.generatorTemplates))
 
-	GeneratorResults = make([]map[string]*drive.JsonFile, 0, len(project.generatorTemplates))
+	names := slices.Sorted(maps.Keys(project.generatorTemplates))
+	// Every goroutine owns a single slot, so no locking is needed
+	project.generatorResults = make([]*GeneratorResult, len(names))
 
-	for _, template := range project.generatorTemplates {
+	for i, name := range names {
+		template := project.generatorTemplates[name]
 		errs.Go(func() error {
-			liblog.Info(
-				1,
-				"Generating from %q with %d definition(s)",
-				template.Root,
-				len(template.Definitions),
-			)
-
-			// TODO: No need to include image/audio/etc. files
-			files_to_generate := []string{}
-			for _, folder := range []string{"data", "assets"} {
-				path := filepath.Join(template.Root, folder)
-				if _, err := os.Stat(path); os.IsNotExist(err) {
-					continue
-				}
+			result, err := project.generateFrom(template)
+			if err != nil {
+				return err
+			}
+			project.generatorResults[i] = result
+			return nil
+		})
+	}
 
-				err := filepath.WalkDir(path, func(p string, entry fs.DirEntry, err error) error {
-					if err != nil || entry.IsDir() {
-						return err
-					}
+	return nil
+}
 
-					p = strings.TrimPrefix(p, filepath.Dir(path))
-					p = strings.TrimPrefix(p, string(filepath.Separator))
-					files_to_generate = append(files_to_generate, p)
-					return nil
-				})
+func (project *Project) generateFrom(template *templates.Generator) (*GeneratorResult, error) {
+	root := template.Root
+	liblog.Info(
+		1,
+		"Generating from %q with %d definition(s)",
+		root,
+		len(template.Definitions),
+	)
+
+	// TODO: No need to include image/audio/etc. files
+	files_to_generate := []string{}
+	for _, folder := range []string{"data", "assets"} {
+		path := filepath.Join(template.Root, folder)
+		if _, err := project.stat(path); os.IsNotExist(err) {
+			continue
+		}
 
-				if err != nil {
-					return liberrors.NewIO(err, path)
+		err := project.walkDir(path, func(p string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+
+			p = strings.TrimPrefix(p, filepath.Dir(path))
+			p = strings.TrimPrefix(p, string(filepath.Separator))
+			files_to_generate = append(files_to_generate, p)
+			return nil
+		})
+
+		if err != nil {
+			return nil, liberrors.NewIO(err, project.pathOf(path))
+		}
+	}
+
+	file_cache := make(map[string][]byte)
+	for _, path := range files_to_generate {
+		src_path := filepath.Join(template.Root, path)
+		data, err := project.readFile(src_path)
+		if err != nil {
+			return nil, liberrors.NewIO(err, project.pathOf(src_path))
+		}
+		file_cache[path] = data
+	}
+
+	liblog.Debug(2, "Loaded %d files to generate per definition", len(files_to_generate))
+	result := &GeneratorResult{
+		Root:  root,
+		Json:  map[string]*drive.JsonFile{},
+		Raw:   map[string][]byte{},
+		Rules: template.MergeRules,
+	}
+
+	// Definitions are applied in a stable order, so that merged files are too
+	for _, name := range slices.Sorted(maps.Keys(template.Definitions)) {
+		definition := template.Definitions[name]
+		for _, path := range files_to_generate {
+			var dest_folder string
+			if strings.HasPrefix(path, "data") {
+				dest_folder = "data_pack"
+			} else if strings.HasPrefix(path, "assets") {
+				dest_folder = "resource_pack"
+			} else {
+				return nil, &liberrors.DetailedError{
+					Label:   liberrors.ERR_INTERNAL,
+					Context: liberrors.DirContext{Path: path},
+					Details: fmt.Sprintf("unknown destination for %q", path),
 				}
 			}
 
-			file_cache := make(map[string][]byte)
-			for _, path := range files_to_generate {
-				src_path := filepath.Join(template.Root, path)
-				data, err := os.ReadFile(src_path)
-				if err != nil {
-					return liberrors.NewIO(err, src_path)
+			dest_path, err := code.SubstituteString(path, definition.Env)
+			if err != nil {
+				return nil, &liberrors.DetailedError{
+					Label:   liberrors.ERR_FORMAT,
+					Context: liberrors.DirContext{Path: path},
+					Details: err.Error(),
 				}
-				file_cache[path] = data
 			}
 
-			liblog.Debug(2, "Loaded %d files to generate per definition", len(files_to_generate))
-			localMap := make(map[string]*drive.JsonFile)
-
-			for _, definition := range template.Definitions {
-				for _, path := range files_to_generate {
-					var dest_folder string
-					if strings.HasPrefix(path, "data") {
-						dest_folder = "data_pack"
-					} else if strings.HasPrefix(path, "assets") {
-						dest_folder = "resource_pack"
-					} else {
-						return &liberrors.DetailedError{
-							Label:   liberrors.ERR_INTERNAL,
-							Context: liberrors.DirContext{Path: path},
-							Details: fmt.Sprintf("unknown destination for %q", path),
-						}
-					}
+			if plan, ok := project.plans[dest_folder]; ok && filepath.Ext(path) != ".mcfunction" {
+				for _, output := range project.outputPathsOf(dest_folder, dest_path) {
+					plan.Graph.Add(filepath.ToSlash(root), filepath.ToSlash(output))
+				}
+			}
+
+			switch filepath.Ext(path) {
 
-					dest_path, err := code.SubstituteString(path, definition.Env)
-					if err != nil {
-						return &liberrors.DetailedError{
-							Label:   liberrors.ERR_FORMAT,
-							Context: liberrors.DirContext{Path: path},
-							Details: err.Error(),
-						}
+			case ".json":
+				// Relative to the build dir, mapped into the folder layout when written
+				dest_path = filepath.Join(dest_folder, dest_path)
+				data := file_cache[path]
+				file := drive.NewJsonFile(data)
+
+				err = code.SubstituteJsonFile(file, definition.Env)
+				if err != nil {
+					return nil, &liberrors.DetailedError{
+						Label:   liberrors.ERR_FORMAT,
+						Context: liberrors.DirContext{Path: path},
+						Details: err.Error(),
 					}
+				}
 
-					switch filepath.Ext(path) {
-
-					case ".json":
-						dest_path = filepath.Join(project.BuildDir, dest_folder, dest_path)
-						data := file_cache[path]
-						file := drive.NewJsonFile(data)
-
-						err = code.SubstituteJsonFile(file, definition.Env)
-						if err != nil {
-							return &liberrors.DetailedError{
-								Label:   liberrors.ERR_FORMAT,
-								Context: liberrors.DirContext{Path: path},
-								Details: err.Error(),
-							}
-						}
-
-						if original, ok := localMap[dest_path]; ok {
-							original.MergeWith(file)
-						} else {
-							localMap[dest_path] = file
-						}
-
-					case ".mcfunction":
-						data := file_cache[path]
-
-						output, err := code.SubstituteString(string(data), definition.Env)
-						if err != nil {
-							return &liberrors.DetailedError{
-								Label:   liberrors.ERR_FORMAT,
-								Context: liberrors.DirContext{Path: dest_path},
-								Details: err.Error(),
-							}
-						}
-
-						scanner := bufio.NewScanner(strings.NewReader(output))
-						fn := mcfunc.New(dest_path, scanner, project.inlineTemplates)
-						proc := mcfunc.NewProcessor(fn)
-						if err := proc.Build(); err != nil {
-							return err
-						}
-
-					default:
-						dest_path = filepath.Join(project.BuildDir, dest_folder, dest_path)
-						liblog.Debug(3, "Copying into %q", dest_path)
-						err := os.WriteFile(dest_path, file_cache[path], os.ModePerm)
-						if err != nil {
-							return liberrors.NewIO(err, path)
-						}
+				if original, ok := result.Json[dest_path]; ok {
+					original.MergeWith(file, template.MergeRules)
+				} else {
+					result.Json[dest_path] = file
+				}
+
+			case ".mcfunction":
+				data := file_cache[path]
+
+				output, err := code.SubstituteString(string(data), definition.Env)
+				if err != nil {
+					return nil, &liberrors.DetailedError{
+						Label:   liberrors.ERR_FORMAT,
+						Context: liberrors.DirContext{Path: dest_path},
+						Details: err.Error(),
 					}
 				}
+
+				scanner := bufio.NewScanner(strings.NewReader(output))
+				fn := mcfunc.New(dest_path, scanner, project.inlineTemplates, project.registry)
+				fn.Source = root
+				proc := mcfunc.NewProcessor(fn)
+				if err := proc.Build(); err != nil {
+					return nil, err
+				}
+
+			default:
+				// Written along with the JSON files, the last definition wins
+				result.Raw[filepath.Join(dest_folder, dest_path)] = file_cache[path]
 			}
+		}
+	}
+
+	liblog.Done(2, "Generated %d file(s)", len(template.Definitions)*len(files_to_generate))
+	return result, nil
+}
+
+// Stops the programs of persistent inline templates, since no functions are processed after this
+func (project *Project) CloseTemplates() error {
+	for _, name := range slices.Sorted(maps.Keys(project.inlineTemplates)) {
+		if err := project.inlineTemplates[name].Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Runs every collector template over the built packs and merges the JSON it prints into them
+func (project *Project) CollectFromTemplates() error {
+	if project.isDataCached && project.isAssetsCached {
+		return nil
+	}
+	if len(project.collectorTemplates) == 0 {
+		return nil
+	}
+
+	liblog.Info(0, "Collecting from %d template(s)", len(project.collectorTemplates))
+
+	// Collectors run one after another, so that each of them sees what the previous ones collected
+	for _, name := range slices.Sorted(maps.Keys(project.collectorTemplates)) {
+		if err := project.collectFrom(project.collectorTemplates[name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
-			liblog.Done(2, "Generated %d file(s)", len(template.Definitions)*len(files_to_generate))
+func (project *Project) collectFrom(template *templates.Collector) error {
+	// Contents of every matched file, each followed by a NUL byte
+	var stdin bytes.Buffer
+	matched := 0
 
-			GeneratorResults = append(GeneratorResults, localMap)
+	for _, pack_dir := range []string{"data_pack", "resource_pack"} {
+		pack := project.packs[pack_dir]
+		err := fs.WalkDir(pack, ".", func(name string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() || !matchesAnyGlob(template.Patterns, name) {
+				return err
+			}
+
+			body, err := fs.ReadFile(pack, name)
+			if err != nil {
+				return err
+			}
+			stdin.Write(body)
+			stdin.WriteByte(0)
+			matched++
 			return nil
 		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return liberrors.NewIO(err, pack_dir)
+		}
+	}
+
+	liblog.Info(1, "Collecting from %q with %d matched file(s)", template.Root, matched)
+
+	program, err := project.executable(path.Join(template.Root, template.Collector))
+	if err != nil {
+		return liberrors.NewIO(err, project.pathOf(template.Root))
+	}
+	cmd := exec.CommandContext(project.ctx, program)
+	cmd.Dir = project.Dir
+	cmd.Env = append(os.Environ(), project.templateEnv(project.buildDirOnDisk())...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = &stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return &liberrors.DetailedError{
+			Label:   liberrors.ERR_EXECUTE,
+			Context: liberrors.NewProgramContext(cmd, stderr.String()),
+			Details: err.Error(),
+		}
+	}
+
+	if stderr.Len() != 0 {
+		liblog.Error(1, "From: %s", template.Root)
+		scanner := bufio.NewScanner(&stderr)
+		for scanner.Scan() {
+			liblog.Error(2, "%s", scanner.Text())
+		}
+	}
+
+	result := gjson.ParseBytes(stdout.Bytes())
+	if !gjson.ValidBytes(stdout.Bytes()) || !result.IsObject() {
+		return &liberrors.DetailedError{
+			Label:   liberrors.ERR_FORMAT,
+			Context: liberrors.NewProgramContext(cmd, stderr.String()),
+			Details: "expected the collector to print a JSON object of files to merge",
+		}
+	}
+
+	files := result.Map()
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		if err := project.mergeCollected(template.Root, name, files[name]); err != nil {
+			return err
+		}
 	}
 
+	liblog.Done(2, "Collected %d file(s)", len(files))
 	return nil
 }
 
-func (project *Project) CollectFromTemplates() error {
+// Merges [value] into [name] (e.g. "assets/<namespace>/lang/en_us.json") inside of the pack it belongs to
+func (project *Project) mergeCollected(root, name string, value gjson.Result) error {
+	folder, _, _ := strings.Cut(name, "/")
+	if folder != FOLDER_DATA && folder != FOLDER_ASSETS {
+		return &liberrors.DetailedError{
+			Label:   liberrors.ERR_FORMAT,
+			Context: liberrors.DirContext{Path: project.pathOf(root)},
+			Details: fmt.Sprintf("collected %q must be inside of %q or %q", name, FOLDER_DATA, FOLDER_ASSETS),
+		}
+	}
+
+	pack_dir := getPackDir(folder)
+	if _, err := project.stat(folder); err != nil {
+		liblog.Warn(1, "%q collected %q, but there is no %q folder. Ignoring it", root, name, folder)
+		return nil
+	}
+
+	pack := project.packs[pack_dir]
+	plan, has_plan := project.plans[pack_dir]
+
+	for _, output := range project.outputPathsOf(pack_dir, filepath.FromSlash(name)) {
+		name_in_pack := filepath.ToSlash(output)
+		if has_plan {
+			plan.Graph.Add(filepath.ToSlash(root), name_in_pack)
+		}
+
+		// Previous results are removed when planning the pack, so this is what other sources wrote
+		source := outputSource{
+			Name: root,
+			Read: func() ([]byte, error) {
+				body, err := fs.ReadFile(pack, name_in_pack)
+				if errors.Is(err, fs.ErrNotExist) {
+					body = []byte("{}")
+				} else if err != nil {
+					return nil, err
+				}
+
+				file := drive.NewJsonFile(body)
+				file.MergeWith(drive.NewJsonFile([]byte(value.Raw)), drive.DefaultMergeRules())
+				return file.Formatted(), nil
+			},
+		}
+
+		output := filepath.Join(pack_dir, output)
+		if err := project.claimOutput(output, source); err != nil {
+			return err
+		}
+
+		liblog.Debug(2, "Merging into %q", output)
+		if err := project.writeOutput(output, source); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+func matchesAnyGlob(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if drive.MatchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
 func (project *Project) RunCustomTemplates() error {
 	if project.isDataCached && project.isAssetsCached {
 		return nil
 	}
+	if len(project.customTemplates) == 0 {
+		return nil
+	}
 
 	liblog.Info(0, "Running %d custom template(s)", len(project.customTemplates))
 
-	for _, template := range project.customTemplates {
-		path := filepath.Join(template.Root, template.Program)
-		cmd := exec.Command(path, project.BuildDir)
+	build_dir, cleanup, err := project.materializeBuild()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	for _, name := range slices.Sorted(maps.Keys(project.customTemplates)) {
+		template := project.customTemplates[name]
+		before := drive.TakeSnapshot(build_dir)
+
+		program, err := project.executable(path.Join(template.Root, template.Program))
+		if err != nil {
+			return liberrors.NewIO(err, project.pathOf(template.Root))
+		}
+		cmd := exec.CommandContext(project.ctx, program, build_dir)
+		cmd.Dir = project.Dir
+		cmd.Env = append(os.Environ(), project.templateEnv(build_dir)...)
 
 		var stderr bytes.Buffer
 		cmd.Stderr = &stderr
@@ -183,45 +414,147 @@ func (project *Project) RunCustomTemplates() error {
 		cmd.Stdin = os.Stdin
 
 		if err := cmd.Run(); err != nil {
-			path = fmt.Sprintf("%s with [%s]", path, project.BuildDir)
 			return &liberrors.DetailedError{
 				Label:   liberrors.ERR_EXECUTE,
 				Context: liberrors.NewProgramContext(cmd, stderr.String()),
 				Details: err.Error(),
 			}
 		}
+
+		if err := project.claimCustomOutputs(template.Root, build_dir, before); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns the project variables passed to the programs of templates, see [templates.ENV_BUILD_DIR]
+func (project *Project) templateEnv(build_dir string) []string {
+	data := project.Meta.Clone().FillVersion("data", minecraft.DataPackFormats).Versions
+	resources := project.Meta.Clone().FillVersion("resources", minecraft.ResourcePackFormats).Versions
+
+	return []string{
+		templates.ENV_BUILD_DIR + "=" + build_dir,
+		templates.ENV_NAME + "=" + project.Meta.Name().String(),
+		templates.ENV_VERSION + "=" + project.Meta.Version().String(),
+		templates.ENV_MINECRAFT + "=" + minecraft.TargetName(project.Meta.Minecraft()),
+		templates.ENV_DATA_PACK_FORMAT_MIN + "=" + fmt.Sprint(data.Min.Value()),
+		templates.ENV_DATA_PACK_FORMAT_MAX + "=" + fmt.Sprint(data.Max.Value()),
+		templates.ENV_RESOURCE_PACK_FORMAT_MIN + "=" + fmt.Sprint(resources.Min.Value()),
+		templates.ENV_RESOURCE_PACK_FORMAT_MAX + "=" + fmt.Sprint(resources.Max.Value()),
+		templates.ENV_PLURAL_FOLDERS + "=" + strconv.FormatBool(project.folderLayout.UsesPlural()),
+	}
+}
+
+// Returns an absolute path to the build dir, or "" if the build is not written to disk
+func (project *Project) buildDirOnDisk() string {
+	if dir, ok := vfs.DirOf(project.Output); ok {
+		return drive.ToAbs(dir)
 	}
+	return ""
+}
+
+// Returns an absolute path to the build dir on disk for external programs.
+// Packs that are not written to disk are copied into a temporary directory.
+func (project *Project) materializeBuild() (string, func(), error) {
+	if dir, ok := vfs.DirOf(project.Output); ok && !project.Options.Zip {
+		// Programs run inside of the project dir, so relative paths would be resolved twice
+		dir, err := filepath.Abs(dir)
+		return dir, func() {}, liberrors.NewIO(err, dir)
+	}
+
+	dir, err := os.MkdirTemp("", "vintage-build-")
+	if err != nil {
+		return "", nil, liberrors.NewIO(err, os.TempDir())
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	for pack_dir, pack := range project.packs {
+		if err := vfs.Copy(vfs.NewDirSink(filepath.Join(dir, pack_dir)), pack); err != nil {
+			cleanup()
+			return "", nil, liberrors.NewIO(err, dir)
+		}
+	}
+	return dir, cleanup, nil
+}
 
+// Claims the files inside of [build_dir] that a custom template created or modified since [before]
+func (project *Project) claimCustomOutputs(root, build_dir string, before drive.Snapshot) error {
+	changed, _ := drive.TakeSnapshot(build_dir).Diff(before)
+	for _, path := range changed {
+		output, err := filepath.Rel(build_dir, path)
+		if err != nil || filepath.Base(output) == cache.FILENAME {
+			continue
+		}
+		if _, ok := project.packs[strings.Split(filepath.ToSlash(output), "/")[0]]; !ok {
+			liblog.Warn(1, "%q created %q outside of the packs. Ignoring it", root, output)
+			continue
+		}
+
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return liberrors.NewIO(err, path)
+		}
+
+		source := bytesSource(root, body)
+		if err := project.claimOutput(output, source); err != nil {
+			return err
+		}
+		if err := project.writeOutput(output, source); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (project *Project) writeGeneratedJsonFiles(errs *errgroup.Group) error {
+func (project *Project) writeGeneratedFiles(errs *errgroup.Group) error {
 	merged := make(map[string]*drive.JsonFile)
-	for _, local := range GeneratorResults {
-		for path, file := range local {
+	// Path → roots of the templates that generated it
+	contributors := make(map[string][]string)
+	// Path → sources in the order of templates. Only JSON files are merged across templates
+	sources := make(map[string][]outputSource)
+
+	for _, result := range project.generatorResults {
+		if result == nil {
+			continue
+		}
+		for _, path := range slices.Sorted(maps.Keys(result.Json)) {
+			file := result.Json[path]
+			contributors[path] = append(contributors[path], result.Root)
 			if original, ok := merged[path]; ok {
-				original.MergeWith(file)
+				// The rules of the template that contributes the file apply
+				original.MergeWith(file, result.Rules)
 			} else {
 				merged[path] = file
 			}
 		}
+		for path, body := range result.Raw {
+			sources[path] = append(sources[path], bytesSource(result.Root, body))
+		}
 	}
-	GeneratorResults = nil
+	project.generatorResults = nil
 
 	for path, file := range merged {
-		errs.Go(func() error {
-			err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
-			if err != nil {
-				return liberrors.NewIO(err, path)
-			}
+		name := strings.Join(contributors[path], ", ")
+		sources[path] = []outputSource{bytesSource(name, file.Formatted())}
+	}
 
-			err = os.WriteFile(path, file.Formatted(), os.ModePerm)
-			if err != nil {
-				return liberrors.NewIO(err, path)
+	for _, path := range slices.Sorted(maps.Keys(sources)) {
+		dest_folder, dest_path, _ := strings.Cut(path, string(filepath.Separator))
+		for _, output := range project.outputPathsOf(dest_folder, dest_path) {
+			output := filepath.Join(dest_folder, output)
+			for _, source := range sources[path] {
+				if err := project.claimOutput(output, source); err != nil {
+					return err
+				}
 			}
 
-			return nil
-		})
+			last := sources[path][len(sources[path])-1]
+			errs.Go(func() error {
+				return project.writeOutput(output, last)
+			})
+		}
 	}
 
 	return nil
@@ -1,14 +1,13 @@
 package devkit
 
 import (
+	"bytes"
 	"fmt"
-	"os"
-	"path/filepath"
-
-	"github.com/klauspost/compress/zip"
+	"io"
 
 	liberrors "github.com/bbfh-dev/lib-errors"
 	liblog "github.com/bbfh-dev/lib-log"
+	"github.com/bbfh-dev/vintage/devkit/vfs"
 )
 
 func (project *Project) ZipPacks() error {
@@ -30,45 +29,35 @@ func (project *Project) ZipPacks() error {
 	return nil
 }
 
-func (project *Project) zip(folder string) error {
-	zip_path := project.getZipPath(getZipLabel(folder))
-
-	folder_path := filepath.Join(project.BuildDir, folder)
-	root, err := os.OpenRoot(folder_path)
-	if err != nil {
-		liblog.Debug(1, "Skipping %q: %s", folder, err.Error())
+func (project *Project) zip(pack_dir string) error {
+	pack := project.packs[pack_dir]
+	if vfs.IsEmpty(pack, ".") {
+		liblog.Debug(1, "Skipping %q: nothing was built", pack_dir)
 		return nil
 	}
 
-	file, err := os.Create(zip_path)
-	if err != nil {
-		return liberrors.NewIO(err, zip_path)
+	writer, ok := pack.(io.WriterTo)
+	if !ok {
+		return fmt.Errorf("(Assertion fail) %q is not written into a .zip file", pack_dir)
 	}
-	defer file.Close()
 
-	writer := zip.NewWriter(file)
-	defer writer.Close()
+	var buffer bytes.Buffer
+	if _, err := writer.WriteTo(&buffer); err != nil {
+		return liberrors.NewIO(err, pack_dir)
+	}
 
-	err = writer.AddFS(root.FS())
-	if err != nil {
-		return liberrors.NewIO(err, folder_path)
+	zip_name := project.getZipName(pack_dir)
+	if err := project.Output.WriteFile(zip_name, buffer.Bytes()); err != nil {
+		return liberrors.NewIO(err, zip_name)
 	}
 
-	liblog.Done(1, "Created %q", zip_path)
+	liblog.Done(1, "Created %q", zip_name)
 	return nil
 }
 
-func (project *Project) getZipPath(label string) string {
-	// TODO: Would be nice to not add label if pack is only one of a kind (only RP or only DP)
-	return filepath.Join(
-		project.BuildDir,
-		fmt.Sprintf(
-			"%s_%s_v%s.zip",
-			project.Meta.Name(),
-			label,
-			project.Meta.VersionFormatted(),
-		),
-	)
+// Returns the name of the exported .zip file inside of the build dir
+func (project *Project) getZipName(pack_dir string) string {
+	return project.outputNames[pack_dir] + ".zip"
 }
 
 func getZipLabel(folder string) string {
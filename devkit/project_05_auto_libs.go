@@ -4,13 +4,13 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 
 	liberrors "github.com/bbfh-dev/lib-errors"
 	liblog "github.com/bbfh-dev/lib-log"
 	"github.com/bbfh-dev/vintage/devkit/internal/autolibs"
 	"github.com/bbfh-dev/vintage/devkit/internal/drive"
-	"github.com/bbfh-dev/vintage/devkit/internal/mcfunc"
 	"github.com/bbfh-dev/vintage/devkit/internal/pipeline"
 	"golang.org/x/sync/errgroup"
 )
@@ -20,12 +20,18 @@ func (project *Project) LoadAutoLibs() error {
 		return nil
 	}
 
-	_, err := os.Stat("libs")
+	_, err := project.stat("libs")
 	if os.IsNotExist(err) {
 		liblog.Debug(0, "No libraries found")
 		return nil
 	}
 
+	// Libraries are downloaded and disabled in place
+	if project.Dir == "" {
+		liblog.Warn(0, "Skipping automatic libraries, the project is not on disk")
+		return nil
+	}
+
 	liblog.Info(0, "Loading automatic libraries")
 	return pipeline.New(
 		pipeline.Async(
@@ -39,12 +45,20 @@ func (project *Project) LoadAutoLibs() error {
 
 func (project *Project) loadLibsFrom(folder string) pipeline.AsyncTask {
 	return func(errs *errgroup.Group) error {
-		entries, err := os.ReadDir(filepath.Join("libs", folder))
+		entries, err := project.readDir(filepath.Join("libs", folder))
 		if err != nil {
 			liblog.Debug(2, "Skipping %q: %s", folder, err.Error())
 			return nil
 		}
 
+		// Functions that were not rebuilt still use their namespaces
+		used_namespaces := []string{}
+		for _, namespaces := range project.namespacesBySource() {
+			used_namespaces = append(used_namespaces, namespaces...)
+		}
+		slices.Sort(used_namespaces)
+		used_namespaces = slices.Compact(used_namespaces)
+
 		for _, entry := range entries {
 			ext := filepath.Ext(entry.Name())
 			if ext != ".json" {
@@ -55,19 +69,20 @@ func (project *Project) loadLibsFrom(folder string) pipeline.AsyncTask {
 			name = strings.ReplaceAll(name, "---", ".*")
 			re := regexp.MustCompile(name)
 
-			path := filepath.Join("libs", folder, entry.Name())
+			path := project.pathOf(filepath.Join("libs", folder, entry.Name()))
 			data, err := os.ReadFile(path)
 			if err != nil {
 				return liberrors.NewIO(err, path)
 			}
 
 			lib := &autolibs.Library{
-				Namespaces: []string{},
-				Path:       path,
-				File:       drive.NewJsonFile(data),
+				Namespaces:   []string{},
+				Path:         path,
+				File:         drive.NewJsonFile(data),
+				DeleteUnused: project.Options.DeleteUnusedLibs,
 			}
 
-			for namespace := range mcfunc.UsedNamespaces {
+			for _, namespace := range used_namespaces {
 				if re.MatchString(namespace) {
 					lib.Namespaces = append(lib.Namespaces, namespace)
 				}
@@ -2,19 +2,16 @@ package devkit
 
 import (
 	"bytes"
-	"errors"
-	"fmt"
+	"io/fs"
 	"os"
-	"os/exec"
-	"path/filepath"
-	"strings"
+	"path"
 	"time"
 
 	liberrors "github.com/bbfh-dev/lib-errors"
 	liblog "github.com/bbfh-dev/lib-log"
 	"github.com/bbfh-dev/vintage/devkit/internal/drive"
 	"github.com/bbfh-dev/vintage/devkit/internal/pipeline"
-	cp "github.com/otiai10/copy"
+	"github.com/bbfh-dev/vintage/devkit/internal/weld"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -23,83 +20,104 @@ func (project *Project) WeldPacks() error {
 		return nil
 	}
 
-	_, err := os.Stat("libs")
+	_, err := project.stat("libs")
 	if os.IsNotExist(err) {
 		liblog.Debug(0, "No libraries found")
 		return nil
 	}
 
-	liblog.Info(0, "Merging with Smithed Weld")
+	liblog.Info(0, "Merging with libraries")
 	return pipeline.New(
 		pipeline.Async(
 			pipeline.If[pipeline.AsyncTask](!project.isDataCached).
-				Then(project.weld("data_packs", project.getZipPath("DP"))),
+				Then(project.weld("data_packs", project.getZipName("data_pack"))),
 			pipeline.If[pipeline.AsyncTask](!project.isAssetsCached).
-				Then(project.weld("resource_packs", project.getZipPath("RP"))),
+				Then(project.weld("resource_packs", project.getZipName("resource_pack"))),
 		),
 	)
 }
 
+// Merges the libraries of libs/[dir] into [zip_name], which takes priority over them
 func (project *Project) weld(dir, zip_name string) pipeline.AsyncTask {
 	return func(errs *errgroup.Group) error {
 		start := time.Now()
-		output_name := fmt.Sprintf("weld-%s.zip", dir)
+		libs_dir := path.Join("libs", dir)
 
-		path := filepath.Join("libs", dir)
-
-		if _, err := os.Stat(path); os.IsNotExist(err) {
+		if _, err := project.stat(libs_dir); os.IsNotExist(err) {
 			liblog.Debug(1, "%q does not exist. Skipping...", dir)
 			return nil
 		}
+		body, err := fs.ReadFile(project.Output, zip_name)
+		if os.IsNotExist(err) {
+			liblog.Debug(1, "%q does not exist. Skipping...", zip_name)
+			return nil
+		}
+		if err != nil {
+			return liberrors.NewIO(err, zip_name)
+		}
 
-		entries, err := readLibDir(path)
+		entries, err := project.readLibDir(libs_dir)
 		if err != nil {
 			return err
 		}
-		entries = append(entries, zip_name)
 
-		if len(entries) < 2 {
+		if len(entries) == 0 {
 			liblog.Debug(1, "No libraries found for %q. Skipping...", dir)
 			return nil
 		}
 
-		args := append([]string{"--dir", project.BuildDir, "--name", output_name}, entries...)
-		liblog.Debug(1, "$ weld %s", strings.Join(args, " "))
-		cmd := exec.Command("weld", args...)
-
-		var out bytes.Buffer
-		cmd.Stdout = &out
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
-			os.Stdout.Write(out.Bytes())
-			return &liberrors.DetailedError{
-				Label:   liberrors.ERR_EXECUTE,
-				Context: liberrors.DirContext{Path: path},
-				Details: err.Error(),
+		packs := make([]weld.Pack, 0, len(entries)+1)
+		for _, entry := range entries {
+			lib_body, err := project.readFile(entry)
+			if err != nil {
+				return liberrors.NewIO(err, project.pathOf(entry))
+			}
+			files, err := drive.ReadZipFiles(lib_body)
+			if err != nil {
+				return liberrors.NewIO(err, project.pathOf(entry))
 			}
+			packs = append(packs, weld.Pack{Name: path.Base(entry), Files: files})
 		}
 
-		path = filepath.Join(project.BuildDir, output_name)
-		err = errors.Join(cp.Copy(path, zip_name), os.Remove(path))
+		files, err := drive.ReadZipFiles(body)
 		if err != nil {
-			return liberrors.NewIO(err, path)
+			return liberrors.NewIO(err, zip_name)
+		}
+		packs = append(packs, weld.Pack{Name: zip_name, Files: files})
+
+		result := weld.Merge(packs...)
+		for _, conflict := range result.Conflicts {
+			liblog.Warn(2, "Conflict in %s", conflict)
+		}
+
+		var buffer bytes.Buffer
+		err = drive.WriteZipFiles(result.Files, &buffer, drive.ZipOptions{
+			Deterministic: project.Options.Reproducible,
+			Level:         project.Options.CompressionLevel,
+		})
+		if err != nil {
+			return liberrors.NewIO(err, zip_name)
+		}
+		if err := project.Output.WriteFile(zip_name, buffer.Bytes()); err != nil {
+			return liberrors.NewIO(err, zip_name)
 		}
 
-		liblog.Done(1, "Merged %q in %s", zip_name, time.Since(start))
+		liblog.Done(1, "Merged %d libraries into %q in %s", len(entries), zip_name, time.Since(start))
 		return nil
 	}
 }
 
-func readLibDir(dir string) ([]string, error) {
-	entries, err := os.ReadDir(dir)
+// Returns the .zip files inside of [dir] (relative to the project)
+func (project *Project) readLibDir(dir string) ([]string, error) {
+	entries, err := project.readDir(dir)
 	if err != nil {
-		return nil, liberrors.NewIO(err, drive.ToAbs("libs"))
+		return nil, liberrors.NewIO(err, project.pathOf(dir))
 	}
 
 	files := make([]string, 0, len(entries)+1)
 	for _, entry := range entries {
-		if filepath.Ext(entry.Name()) == ".zip" {
-			files = append(files, filepath.Join(dir, entry.Name()))
+		if path.Ext(entry.Name()) == ".zip" {
+			files = append(files, path.Join(dir, entry.Name()))
 		}
 	}
 
@@ -0,0 +1,188 @@
+package vfs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bbfh-dev/vintage/devkit/internal/drive"
+)
+
+// Sink that keeps every file in memory. Safe for concurrent use
+type MemorySink struct {
+	mutex sync.RWMutex
+	files map[string][]byte
+}
+
+func NewMemorySink() *MemorySink {
+	return &MemorySink{files: map[string][]byte{}}
+}
+
+// Returns a copy of every file ('/' separated name → contents)
+func (sink *MemorySink) Files() map[string][]byte {
+	sink.mutex.RLock()
+	defer sink.mutex.RUnlock()
+	return maps.Clone(sink.files)
+}
+
+func (sink *MemorySink) WriteFile(name string, body []byte) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	sink.files[name] = bytes.Clone(body)
+	return nil
+}
+
+func (sink *MemorySink) RemoveAll(name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	for file := range sink.files {
+		if isInside(file, name) {
+			delete(sink.files, file)
+		}
+	}
+	return nil
+}
+
+func (sink *MemorySink) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	sink.mutex.RLock()
+	defer sink.mutex.RUnlock()
+
+	if body, ok := sink.files[name]; ok {
+		return &memoryFile{
+			Reader: bytes.NewReader(body),
+			info:   memoryInfo{name: path.Base(name), size: int64(len(body))},
+		}, nil
+	}
+
+	// Directories only exist implicitly as the parents of files
+	children := map[string]memoryInfo{}
+	for file, body := range sink.files {
+		if !isInside(file, name) || file == name {
+			continue
+		}
+		rest := strings.TrimPrefix(file, name+"/")
+		if name == "." {
+			rest = file
+		}
+		child, _, is_dir := strings.Cut(rest, "/")
+		children[child] = memoryInfo{name: child, size: int64(len(body)), isDir: is_dir}
+	}
+	if len(children) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, child := range slices.Sorted(maps.Keys(children)) {
+		info := children[child]
+		if info.isDir {
+			info.size = 0
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	return &memoryDir{info: memoryInfo{name: path.Base(name), isDir: true}, entries: entries}, nil
+}
+
+// Sink that keeps every file in memory to write them as a .zip archive
+type ZipSink struct {
+	*MemorySink
+	options drive.ZipOptions
+}
+
+type ZipOptions = drive.ZipOptions
+
+func NewZipSink(options ZipOptions) *ZipSink {
+	return &ZipSink{MemorySink: NewMemorySink(), options: options}
+}
+
+// Writes the current files into [out] as a .zip archive.
+// Can be called any number of times.
+func (sink *ZipSink) WriteTo(out io.Writer) (int64, error) {
+	counter := &countingWriter{Writer: out}
+	err := drive.WriteZipFiles(sink.Files(), counter, sink.options)
+	return counter.n, err
+}
+
+// ————————————————————————————————
+
+type memoryInfo struct {
+	name  string
+	size  int64
+	isDir bool
+}
+
+func (info memoryInfo) Name() string       { return info.name }
+func (info memoryInfo) Size() int64        { return info.size }
+func (info memoryInfo) ModTime() time.Time { return time.Time{} }
+func (info memoryInfo) IsDir() bool        { return info.isDir }
+func (info memoryInfo) Sys() any           { return nil }
+
+func (info memoryInfo) Mode() fs.FileMode {
+	if info.isDir {
+		return fs.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+type memoryFile struct {
+	*bytes.Reader
+	info memoryInfo
+}
+
+func (file *memoryFile) Stat() (fs.FileInfo, error) { return file.info, nil }
+func (file *memoryFile) Close() error               { return nil }
+
+type memoryDir struct {
+	info    memoryInfo
+	entries []fs.DirEntry
+}
+
+func (dir *memoryDir) Stat() (fs.FileInfo, error) { return dir.info, nil }
+func (dir *memoryDir) Close() error               { return nil }
+
+func (dir *memoryDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: dir.info.name, Err: fs.ErrInvalid}
+}
+
+func (dir *memoryDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := dir.entries
+		dir.entries = nil
+		return entries, nil
+	}
+	if len(dir.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(dir.entries))
+	entries := dir.entries[:n]
+	dir.entries = dir.entries[n:]
+	return entries, nil
+}
+
+type countingWriter struct {
+	io.Writer
+	n int64
+}
+
+func (writer *countingWriter) Write(p []byte) (int, error) {
+	n, err := writer.Writer.Write(p)
+	writer.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,143 @@
+// Writable file trees that builds output into
+package vfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Writable file tree that a build outputs into.
+// Names are '/' separated and relative to the root of the sink, see [fs.ValidPath].
+type Sink interface {
+	// Reads back what was written
+	fs.FS
+	// Writes [body] into [name], creating parent directories as needed
+	WriteFile(name string, body []byte) error
+	// Removes [name] and everything inside of it. Missing names are ignored
+	RemoveAll(name string) error
+}
+
+// Sink that writes into a directory on disk
+type DirSink struct {
+	root string
+	fsys fs.FS
+}
+
+func NewDirSink(root string) *DirSink {
+	return &DirSink{root: root, fsys: os.DirFS(root)}
+}
+
+// Returns the directory that the sink writes into
+func (sink *DirSink) Root() string {
+	return sink.root
+}
+
+func (sink *DirSink) Open(name string) (fs.File, error) {
+	return sink.fsys.Open(name)
+}
+
+func (sink *DirSink) WriteFile(name string, body []byte) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+
+	path := filepath.Join(sink.root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(path, body, os.ModePerm)
+}
+
+func (sink *DirSink) RemoveAll(name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	return os.RemoveAll(filepath.Join(sink.root, filepath.FromSlash(name)))
+}
+
+// Sink that writes into [dir] of another sink
+type SubSink struct {
+	parent Sink
+	dir    string
+}
+
+// Returns a sink that writes into [dir] of [parent].
+// Returns [parent] itself if [dir] is ".".
+func Sub(parent Sink, dir string) Sink {
+	if dir == "." {
+		return parent
+	}
+	return &SubSink{parent: parent, dir: dir}
+}
+
+func (sink *SubSink) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	return sink.parent.Open(path.Join(sink.dir, name))
+}
+
+func (sink *SubSink) WriteFile(name string, body []byte) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	return sink.parent.WriteFile(path.Join(sink.dir, name), body)
+}
+
+func (sink *SubSink) RemoveAll(name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	return sink.parent.RemoveAll(path.Join(sink.dir, name))
+}
+
+// Returns the directory on disk that [sink] writes into, if it does
+func DirOf(sink Sink) (string, bool) {
+	switch sink := sink.(type) {
+	case *DirSink:
+		return sink.root, true
+	case *SubSink:
+		if dir, ok := DirOf(sink.parent); ok {
+			return filepath.Join(dir, filepath.FromSlash(sink.dir)), true
+		}
+	}
+	return "", false
+}
+
+// Copies every file of [fsys] into [sink]
+func Copy(sink Sink, fsys fs.FS) error {
+	return fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		body, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		return sink.WriteFile(name, body)
+	})
+}
+
+// Whether [name] is missing or is a directory without any files inside of it
+func IsEmpty(fsys fs.FS, name string) bool {
+	is_empty := true
+	err := fs.WalkDir(fsys, name, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			is_empty = false
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return is_empty && (err == nil || errors.Is(err, fs.ErrNotExist))
+}
+
+func isInside(name, dir string) bool {
+	return dir == "." || name == dir || strings.HasPrefix(name, dir+"/")
+}
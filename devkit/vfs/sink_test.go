@@ -0,0 +1,50 @@
+package vfs_test
+
+import (
+	"bytes"
+	"testing"
+	"testing/fstest"
+
+	"github.com/bbfh-dev/vintage/devkit/internal/drive"
+	"github.com/bbfh-dev/vintage/devkit/vfs"
+	"gotest.tools/assert"
+)
+
+func TestSinks(t *testing.T) {
+	sinks := map[string]vfs.Sink{
+		"dir":    vfs.NewDirSink(t.TempDir()),
+		"memory": vfs.NewMemorySink(),
+		"sub":    vfs.Sub(vfs.NewMemorySink(), "build"),
+	}
+
+	for name, sink := range sinks {
+		t.Run(name, func(t *testing.T) {
+			assert.NilError(t, sink.WriteFile("data/ns/a.json", []byte("a")))
+			assert.NilError(t, sink.WriteFile("data/ns/b/c.json", []byte("c")))
+			assert.NilError(t, sink.WriteFile("pack.mcmeta", []byte("{}")))
+			assert.NilError(t, fstest.TestFS(sink, "data/ns/a.json", "data/ns/b/c.json", "pack.mcmeta"))
+
+			assert.NilError(t, sink.RemoveAll("data/ns/b"))
+			assert.Assert(t, vfs.IsEmpty(sink, "data/ns/b"))
+			assert.Assert(t, !vfs.IsEmpty(sink, "data"))
+			assert.NilError(t, fstest.TestFS(sink, "data/ns/a.json", "pack.mcmeta"))
+		})
+	}
+}
+
+func TestZipSink(t *testing.T) {
+	sink := vfs.NewZipSink(vfs.ZipOptions{Deterministic: true, Level: 6})
+	assert.NilError(t, sink.WriteFile("data/ns/a.json", []byte("a")))
+	assert.NilError(t, sink.WriteFile("pack.mcmeta", []byte("{}")))
+
+	var buffer bytes.Buffer
+	_, err := sink.WriteTo(&buffer)
+	assert.NilError(t, err)
+
+	files, err := drive.ReadZipFiles(buffer.Bytes())
+	assert.NilError(t, err)
+	assert.DeepEqual(t, files, map[string][]byte{
+		"data/ns/a.json": []byte("a"),
+		"pack.mcmeta":    []byte("{}"),
+	})
+}
@@ -0,0 +1,98 @@
+package devkit
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	liberrors "github.com/bbfh-dev/lib-errors"
+	liblog "github.com/bbfh-dev/lib-log"
+	"github.com/bbfh-dev/vintage/cli"
+	"github.com/bbfh-dev/vintage/devkit/internal/drive"
+	"github.com/bbfh-dev/vintage/devkit/minecraft"
+)
+
+// Files and folders that trigger a rebuild when changed
+var watchedPaths = []string{
+	FOLDER_DATA,
+	FOLDER_ASSETS,
+	minecraft.OVERLAYS_DIR,
+	"templates",
+	"libs",
+	"pack.mcmeta",
+	"pack.png",
+}
+
+func Watch(raw_args []string) error {
+	// Sync DEBUG
+	if cli.Main.Options.Debug || cli.Watch.Options.Debug {
+		cli.Main.Options.Debug = true
+		cli.Watch.Options.Debug = true
+		liblog.LogLevel = liblog.LEVEL_DEBUG
+	}
+
+	options := Options{
+		Output:           cli.Watch.Options.Output,
+		Zip:              cli.Watch.Options.Zip,
+		DeleteUnusedLibs: cli.Watch.Options.DeleteUnusedLibs,
+		ForceStringify:   cli.Watch.Options.ForceStringify,
+		KeepMeta:         cli.Watch.Options.KeepMeta,
+		Targets:          splitTargets(cli.Watch.Options.Target),
+		Reproducible:     cli.Watch.Options.Reproducible,
+		CompressionLevel: cli.Watch.Options.CompressionLevel,
+	}
+	if err := options.Validate(); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	dir := workDirOf(cli.Watch.Args.WorkDir)
+	watched_paths := make([]string, len(watchedPaths))
+	for i, path := range watchedPaths {
+		watched_paths[i] = filepath.Join(dir, path)
+	}
+
+	// Every rebuild goes through the build cache, so only the sources that changed
+	// (and those sharing outputs with them) are processed again, for every target
+	builder := NewBuilder(options)
+	snapshot := drive.TakeSnapshot(watched_paths...)
+	if err := builder.Build(ctx, dir); err != nil {
+		liberrors.Print(err, os.Stderr)
+	}
+
+	interval := time.Duration(max(cli.Watch.Options.Interval, 10)) * time.Millisecond
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	liblog.Info(0, "Watching for changes. Press Ctrl+C to stop")
+	for {
+		select {
+		case <-ctx.Done():
+			liblog.Info(0, "Stopped watching")
+			return nil
+		case <-ticker.C:
+		}
+
+		new_snapshot := drive.TakeSnapshot(watched_paths...)
+		changed, removed := new_snapshot.Diff(snapshot)
+		if len(changed) == 0 && len(removed) == 0 {
+			continue
+		}
+		snapshot = new_snapshot
+
+		for _, path := range changed {
+			liblog.Debug(1, "Changed %q", path)
+		}
+		for _, path := range removed {
+			liblog.Debug(1, "Removed %q", path)
+		}
+
+		if err := builder.Build(ctx, dir); err != nil {
+			liberrors.Print(err, os.Stderr)
+		}
+	}
+}
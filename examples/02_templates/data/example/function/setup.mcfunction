@@ -4,3 +4,15 @@
 		kill @e
 
 	say "Done."
+
+tellraw @a {"translate": "example.setup.done", "fallback": "Setup is done"}
+
+#~>log info
+	Setup is done
+
+#~>announce hello world
+
+#~>if entity @a[tag=debug]
+	say Debugging
+#~>> else
+	say Not debugging
@@ -2,5 +2,8 @@
 # 'call' file can have any extension, however it must be an executable!
 # any nested functions should be obtained by reading from stdin
 # any output must be written to stdout.
+# warnings and errors can be reported by writing JSON lines to stderr, e.g.:
+#   {"severity": "error", "message": "something is wrong", "line": 1}
+# where "line" (optional) points into the nested body. Errors fail the build.
 
 echo $1 \"$2\"
@@ -0,0 +1,3 @@
+#~>add_quotes say `%[text]`
+#~>log
+	Announced %[text]
@@ -2,3 +2,7 @@
 
 # Custom templates are always run after the code has finished generating.
 # You can freely read/write to the build directory, which you can access from the first argument ($1 for bash).
+# The project is described by VINTAGE_* environment variables, e.g. the targeted version and pack formats.
+
+echo "tellraw @a \"Built $VINTAGE_NAME v$VINTAGE_VERSION for Minecraft $VINTAGE_MINECRAFT (data pack format $VINTAGE_DATA_PACK_FORMAT_MIN)\"" \
+	>"$1/data_pack/data/example/function/version.mcfunction"
@@ -1,17 +1,18 @@
-# Example python script that would collect all used translation keys.
-import sys, json
-
-en_us: dict[str, str] = {}
+#!/bin/python
+# 'collect' file can have any extension, however it must be an executable!
+# Example python script that collects all used translation keys.
+# Every matched file is written to stdin, followed by a NUL byte.
+# The printed object maps files (as in the project, e.g. "assets/...") to JSON that is merged into them.
+import sys, json, re
 
+TRANSLATE = re.compile(r'"translate"\s*:\s*"([^"]+)"(?:\s*,\s*"fallback"\s*:\s*"([^"]*)")?')
 
-def extract_translation_keys(line: str) -> tuple[str, str]:
-    return "key1.example.com", "value"
-
+en_us: dict[str, str] = {}
 
 for chunk in sys.stdin.buffer.read().split(b"\0"):
     if chunk:
         code = chunk.decode("utf-8", errors="replace")
-        key, value = extract_translation_keys(code)  # TODO
-        en_us[key] = value
+        for key, fallback in TRANSLATE.findall(code):
+            en_us[key] = fallback or key
 
-json.dump({"en_us": en_us}, sys.stdout)
+json.dump({"assets/example/lang/en_us.json": en_us}, sys.stdout)
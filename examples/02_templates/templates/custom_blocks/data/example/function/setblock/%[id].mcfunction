@@ -3,3 +3,5 @@ loot spawn ~ ~ ~ loot example:%[id]
 #~>insert_function ./_nested_function
 	say "123"
 	say "abc"
+#~>log info
+	Placed %[id]
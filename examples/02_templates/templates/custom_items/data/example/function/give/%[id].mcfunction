@@ -0,0 +1 @@
+give @s %[item][custom_name='"%[id]"']
@@ -1,5 +1,5 @@
-scoreboard players set #%[name] %[objective] 0
-function ./_for_%[name]
+scoreboard players set #%[name]_%[call.id] %[objective] 0
+function ./_for_%[name]_%[call.counter]
 	%[...]
-	scoreboard players add #%[name] %[objective] 1
-	execute if score #%[name] %[objective] matches %[range] run function ./_for_%[name]
+	scoreboard players add #%[name]_%[call.id] %[objective] 1
+	execute if score #%[name]_%[call.id] %[objective] matches %[range] run function ./_for_%[name]_%[call.counter]
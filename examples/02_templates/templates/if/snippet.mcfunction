@@ -0,0 +1,5 @@
+execute store success score #if_%[call.id] zzz.tmp if %[condition]
+execute if score #if_%[call.id] zzz.tmp matches 1 run function ./_then_%[call.counter]
+	%[...then]
+execute if score #if_%[call.id] zzz.tmp matches 0 run function ./_else_%[call.counter]
+	%[...else]
@@ -0,0 +1,25 @@
+#!/bin/python
+# Persistent programs are started once per build rather than once per call.
+# Every call is a JSON object per line on stdin:
+#   {"id": 1, "args": [...], "body": [...], "slots": {...}, "function": "data/...", "line": 1, "call": {"id": "...", ...}}
+# and must be answered (in any order) with a JSON object per line on stdout:
+#   {"id": 1, "output": [...], "diagnostics": [{"severity": "warning", "message": "..."}]}
+
+import sys, json
+
+LEVELS = {"info": "gray", "warn": "yellow", "error": "red"}
+
+for frame in sys.stdin:
+    call = json.loads(frame)
+    level = call["args"][0]
+    diagnostics = []
+    if level not in LEVELS:
+        diagnostics.append({"severity": "warning", "message": f"unknown log level {level!r}"})
+
+    output = []
+    for line in call["body"]:
+        if line.strip():
+            text = {"text": f"[{level}] {line.strip()}", "color": LEVELS.get(level, "white")}
+            output.append("tellraw @a[tag=debug] " + json.dumps(text))
+
+    print(json.dumps({"id": call["id"], "output": output, "diagnostics": diagnostics}), flush=True)
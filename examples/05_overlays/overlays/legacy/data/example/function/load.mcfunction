@@ -0,0 +1,2 @@
+function ./_greet
+	say "Hello from 1.20"
@@ -0,0 +1 @@
+loot spawn ~ ~ ~ loot example:reward
@@ -7,7 +7,6 @@ require (
 	github.com/bbfh-dev/lib-log v0.1.2-beta.2
 	github.com/bbfh-dev/lib-parsex/v3 v3.0.3-beta.1
 	github.com/klauspost/compress v1.18.4
-	github.com/otiai10/copy v1.14.1
 	github.com/schollz/progressbar/v3 v3.19.0
 	github.com/tidwall/gjson v1.18.0
 	github.com/tidwall/pretty v1.2.1
@@ -21,7 +20,6 @@ require (
 	github.com/google/go-cmp v0.7.0 // indirect
 	github.com/iancoleman/strcase v0.3.0 // indirect
 	github.com/mitchellh/colorstring v0.0.0-20190213212951-d06e56a500db // indirect
-	github.com/otiai10/mint v1.6.3 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
 	github.com/tidwall/match v1.2.0 // indirect
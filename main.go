@@ -18,6 +18,7 @@ var MainProgram = libparsex.Program{
 	Commands: []*libparsex.Program{
 		&cli.InitProgram,
 		&BuildProgram,
+		&WatchProgram,
 	},
 	EntryPoint: func(rawArgs []string) error {
 		return libparsex.PrintHelpErr
@@ -33,6 +34,15 @@ var BuildProgram = libparsex.Program{
 	EntryPoint:  devkit.Build,
 }
 
+var WatchProgram = libparsex.Program{
+	Name:        "watch",
+	Description: "Build the data & resource packs and rebuild whenever the sources change",
+	Options:     &cli.Watch.Options,
+	Args:        &cli.Watch.Args,
+	Commands:    []*libparsex.Program{},
+	EntryPoint:  devkit.Watch,
+}
+
 func main() {
 	err := libparsex.Run(&MainProgram, os.Args[1:])
 	if err != nil {
@@ -0,0 +1,43 @@
+package vintage_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	liblog "github.com/bbfh-dev/lib-log"
+	"github.com/bbfh-dev/vintage/devkit"
+	"gotest.tools/assert"
+)
+
+// A library used by a function that is not rebuilt must stay installed
+func TestIncrementalBuildKeepsLibraries(t *testing.T) {
+	liblog.Output = t.Output()
+	dir := t.TempDir()
+	files := map[string]string{
+		"pack.mcmeta":                     TEST_PACK_MCMETA,
+		"data/test/function/a.mcfunction": "function lib:run",
+		"data/test/function/b.mcfunction": "say 1",
+		"libs/data_packs/lib.json":        `{"download": "https://example.com/%[namespace].zip", "installed": ["lib.zip"]}`,
+		"libs/data_packs/lib.zip":         "",
+		"libs/resource_packs/.gitkeep":    "",
+		"assets/test/lang/en_us.json":     `{}`,
+	}
+	for name, body := range files {
+		path := filepath.Join(dir, name)
+		assert.NilError(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+		assert.NilError(t, os.WriteFile(path, []byte(body), 0o644))
+	}
+
+	builder := devkit.NewBuilder(devkit.Options{Output: "build"})
+	assert.NilError(t, builder.Build(t.Context(), dir))
+
+	// Only the data pack is rebuilt, then only the resource pack
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "data/test/function/b.mcfunction"), []byte("say 2"), 0o644))
+	assert.NilError(t, builder.Build(t.Context(), dir))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "assets/test/lang/en_us.json"), []byte(`{"a": "1"}`), 0o644))
+	assert.NilError(t, builder.Build(t.Context(), dir))
+
+	_, err := os.Stat(filepath.Join(dir, "libs/data_packs/lib.zip"))
+	assert.NilError(t, err)
+}
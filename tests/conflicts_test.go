@@ -0,0 +1,82 @@
+package vintage_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/tidwall/gjson"
+	"gotest.tools/assert"
+)
+
+// "test:main/nested" is written both by the nested block of "test:main" and by its own file
+func TestFunctionContributions(t *testing.T) {
+	files := map[string]string{
+		"data/test/function/main.mcfunction":        "function ./nested\n\tsay from main",
+		"data/test/function/main/nested.mcfunction": "say from nested",
+	}
+
+	for conflicts, expect := range map[string]string{
+		"":         "say from main\nsay from nested",
+		"warn":     "say from main\nsay from nested",
+		"merge":    "say from main\nsay from nested",
+		"override": "say from nested",
+	} {
+		files["pack.mcmeta"] = `{"meta": {"name": "test", "minecraft": "1.21.11", "version": "1.0.0", "conflicts": "` + conflicts + `"}}`
+		if conflicts == "" {
+			files["pack.mcmeta"] = TEST_PACK_MCMETA
+		}
+
+		sink, err := buildProject(t, files)
+		assert.NilError(t, err, conflicts)
+		body, err := fs.ReadFile(sink, "data_pack/data/test/function/main/nested.mcfunction")
+		assert.NilError(t, err, conflicts)
+		assert.Equal(t, string(body), expect, conflicts)
+	}
+
+	files["pack.mcmeta"] = `{"meta": {"name": "test", "minecraft": "1.21.11", "version": "1.0.0", "conflicts": "error"}}`
+	_, err := buildProject(t, files)
+	assert.ErrorContains(t, err, "conflicting writes")
+}
+
+// What collectors merge into a file written by another source is subject to 'meta.conflicts'
+func TestCollectorConflicts(t *testing.T) {
+	files := map[string]string{
+		"templates/keys/manifest.json":       `{"type": "collector", "patterns": ["**/*.mcfunction"]}`,
+		"templates/keys/collect.sh":          "#!/bin/sh\ncat > /dev/null\necho '{\"assets/test/lang/en_us.json\": {\"b\": \"2\"}}'",
+		"data/test/function/main.mcfunction": "say 1",
+		"assets/test/lang/en_us.json":        `{"a": "1"}`,
+	}
+
+	for _, conflicts := range []string{"warn", "merge", "override"} {
+		files["pack.mcmeta"] = `{"meta": {"name": "test", "minecraft": "1.21.11", "version": "1.0.0", "conflicts": "` + conflicts + `"}}`
+		sink, err := buildProject(t, files)
+		assert.NilError(t, err, conflicts)
+		body, err := fs.ReadFile(sink, "resource_pack/assets/test/lang/en_us.json")
+		assert.NilError(t, err, conflicts)
+		assert.Equal(t, gjson.GetBytes(body, "a").String(), "1", conflicts)
+		assert.Equal(t, gjson.GetBytes(body, "b").String(), "2", conflicts)
+	}
+
+	files["pack.mcmeta"] = `{"meta": {"name": "test", "minecraft": "1.21.11", "version": "1.0.0", "conflicts": "error"}}`
+	_, err := buildProject(t, files)
+	assert.ErrorContains(t, err, "conflicting writes")
+}
+
+// Both folder names of a loot table are written to the same path, whichever write finishes last
+func TestMergeIsDeterministic(t *testing.T) {
+	files := map[string]string{
+		"pack.mcmeta":                        `{"meta": {"name": "test", "minecraft": "1.21.11", "version": "1.0.0", "conflicts": "merge"}}`,
+		"data/test/loot_table/reward.json":   `{"a": "1"}`,
+		"data/test/loot_tables/reward.json":  `{"b": "2"}`,
+		"data/test/function/main.mcfunction": "say 1",
+	}
+
+	for range 50 {
+		sink, err := buildProject(t, files)
+		assert.NilError(t, err)
+		body, err := fs.ReadFile(sink, "data_pack/data/test/loot_table/reward.json")
+		assert.NilError(t, err)
+		assert.Equal(t, gjson.GetBytes(body, "a").String(), "1")
+		assert.Equal(t, gjson.GetBytes(body, "b").String(), "2")
+	}
+}
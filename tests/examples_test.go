@@ -1,45 +1,213 @@
 package vintage_test
 
 import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	liblog "github.com/bbfh-dev/lib-log"
-	"github.com/bbfh-dev/vintage/cli"
 	"github.com/bbfh-dev/vintage/devkit"
+	"github.com/bbfh-dev/vintage/devkit/vfs"
+	"github.com/tidwall/gjson"
+	"golang.org/x/sync/errgroup"
 	"gotest.tools/assert"
 )
 
 func TestExamples(t *testing.T) {
-	work_dir, err := os.Getwd()
+	entries, err := os.ReadDir("../examples")
 	assert.NilError(t, err)
 
-	work_dir = filepath.Join(work_dir, "..")
-	assert.NilError(t, os.Chdir(work_dir))
+	liblog.LogLevel = liblog.LEVEL_DEBUG
+	builder := devkit.NewBuilder(devkit.Options{
+		Output: filepath.Join(os.TempDir(), "vintage-test"),
+		Zip:    true,
+		Force:  true,
+	})
 
-	entries, err := os.ReadDir("examples")
-	assert.NilError(t, err)
+	for _, entry := range entries {
+		assert.NilError(t, os.RemoveAll(builder.Options.Output))
 
-	cli.Main.Options.Debug = testing.Verbose()
-	liblog.LogLevel = liblog.LEVEL_DEBUG
-	cli.Build.Options.Force = true
-	cli.Build.Options.Output = filepath.Join(os.TempDir(), "vintage-test")
-	cli.Build.Options.Zip = true
+		t.Run(entry.Name(), func(t *testing.T) {
+			liblog.Output = t.Output()
+			err := builder.Build(t.Context(), filepath.Join("..", "examples", entry.Name()))
+			assert.NilError(t, err)
+		})
+	}
+}
+
+// Every example must produce byte-identical output when built twice
+func TestExamplesAreStable(t *testing.T) {
+	entries, err := os.ReadDir("../examples")
+	assert.NilError(t, err)
 
 	for _, entry := range entries {
-		path := filepath.Join("examples", entry.Name())
+		t.Run(entry.Name(), func(t *testing.T) {
+			liblog.Output = t.Output()
+			outputs := [2]map[string]string{}
+			for i := range outputs {
+				options := devkit.Options{
+					Output:       filepath.Join(t.TempDir(), "build"),
+					Zip:          true,
+					Force:        true,
+					Reproducible: true,
+				}
+				dir := filepath.Join("..", "examples", entry.Name())
+				assert.NilError(t, devkit.NewBuilder(options).Build(t.Context(), dir))
+				outputs[i] = readTree(t, options.Output)
+			}
+			assert.Assert(t, len(outputs[0]) != 0)
+			assert.DeepEqual(t, outputs[0], outputs[1])
+		})
+	}
+}
 
-		// Reset
-		devkit.Reset()
-		assert.NilError(t, os.RemoveAll(cli.Build.Options.Output))
-		assert.NilError(t, os.Chdir(work_dir))
+// Building from an [fs.FS] into memory must give the same output as building on disk
+func TestExamplesInMemory(t *testing.T) {
+	entries, err := os.ReadDir("../examples")
+	assert.NilError(t, err)
 
+	for _, entry := range entries {
 		t.Run(entry.Name(), func(t *testing.T) {
 			liblog.Output = t.Output()
-			cli.Build.Args.WorkDir = &path
-			err := devkit.Build([]string{path})
-			assert.NilError(t, err)
+			dir := filepath.Join("..", "examples", entry.Name())
+			options := devkit.Options{
+				Output:       filepath.Join(t.TempDir(), "build"),
+				Zip:          true,
+				Force:        true,
+				Reproducible: true,
+			}
+			assert.NilError(t, devkit.NewBuilder(options).Build(t.Context(), dir))
+
+			sink := vfs.NewMemorySink()
+			options.Sink = sink
+			assert.NilError(t, devkit.NewBuilder(options).BuildFS(t.Context(), os.DirFS(dir)))
+
+			tree := map[string]string{}
+			for name, body := range sink.Files() {
+				if path.Base(name) != ".vintage_cache.json" {
+					tree[filepath.FromSlash(name)] = string(body)
+				}
+			}
+			// Guards against both builds silently producing nothing
+			assert.Assert(t, len(tree) != 0)
+			assert.DeepEqual(t, tree, readTree(t, options.Output))
 		})
 	}
 }
+
+// Builds share no state, so every example can be built at once with the same output as when built alone
+func TestConcurrentBuilds(t *testing.T) {
+	entries, err := os.ReadDir("../examples")
+	assert.NilError(t, err)
+
+	liblog.Output = t.Output()
+	newOptions := func() devkit.Options {
+		return devkit.Options{
+			Output:       filepath.Join(t.TempDir(), "build"),
+			Zip:          true,
+			Force:        true,
+			Reproducible: true,
+		}
+	}
+
+	expected := map[string]map[string]string{}
+	for _, entry := range entries {
+		options := newOptions()
+		assert.NilError(t, devkit.NewBuilder(options).Build(t.Context(), filepath.Join("..", "examples", entry.Name())))
+		expected[entry.Name()] = readTree(t, options.Output)
+	}
+
+	outputs := map[string]string{}
+	var errs errgroup.Group
+	for _, entry := range entries {
+		for i := range 2 {
+			options := newOptions()
+			outputs[fmt.Sprintf("%s#%d", entry.Name(), i)] = options.Output
+			errs.Go(func() error {
+				return devkit.NewBuilder(options).Build(t.Context(), filepath.Join("..", "examples", entry.Name()))
+			})
+		}
+	}
+	assert.NilError(t, errs.Wait())
+
+	for name, output := range outputs {
+		example, _, _ := strings.Cut(name, "#")
+		assert.Assert(t, len(expected[example]) != 0, example)
+		assert.DeepEqual(t, readTree(t, output), expected[example])
+	}
+}
+
+// The "auto_lang" collector merges translation keys used by functions into the lang file
+func TestCollectorTemplates(t *testing.T) {
+	// Unbuffered programs flush lines in several writes, which must not break the inline templates of the example
+	t.Setenv("PYTHONUNBUFFERED", "1")
+	liblog.Output = t.Output()
+	sink := vfs.NewMemorySink()
+	builder := devkit.NewBuilder(devkit.Options{Sink: sink, Force: true})
+	assert.NilError(t, builder.Build(t.Context(), filepath.Join("..", "examples", "02_templates")))
+
+	body, err := fs.ReadFile(sink, "resource_pack/assets/example/lang/en_us.json")
+	assert.NilError(t, err)
+	assert.Equal(t, gjson.GetBytes(body, "example\\.title").String(), "Example")
+	assert.Equal(t, gjson.GetBytes(body, "example\\.setup\\.done").String(), "Setup is done")
+}
+
+// Zips are deflated unless compression is turned off, even if no level is given
+func TestCompressionLevel(t *testing.T) {
+	liblog.Output = t.Output()
+	for level, method := range map[int]uint16{0: zip.Deflate, 9: zip.Deflate, devkit.NO_COMPRESSION: zip.Store} {
+		output := filepath.Join(t.TempDir(), "build")
+		builder := devkit.NewBuilder(devkit.Options{Output: output, Zip: true, Force: true, CompressionLevel: level})
+		assert.NilError(t, builder.Build(t.Context(), filepath.Join("..", "examples", "01_basic")))
+
+		archives, err := filepath.Glob(filepath.Join(output, "*.zip"))
+		assert.NilError(t, err)
+		assert.Assert(t, len(archives) != 0)
+		for _, archive := range archives {
+			reader, err := zip.OpenReader(archive)
+			assert.NilError(t, err)
+			for _, file := range reader.File {
+				if !file.FileInfo().IsDir() {
+					assert.Equal(t, file.Method, method, "level %d: %s", level, file.Name)
+				}
+			}
+			reader.Close()
+		}
+	}
+}
+
+func TestBuildIsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	liblog.Output = t.Output()
+	builder := devkit.NewBuilder(devkit.Options{Output: filepath.Join(t.TempDir(), "build")})
+	err := builder.Build(ctx, filepath.Join("..", "examples", "01_basic"))
+	assert.Assert(t, errors.Is(err, context.Canceled))
+}
+
+// Returns the contents of every file in [root], except the build cache
+func readTree(t *testing.T, root string) map[string]string {
+	tree := map[string]string{}
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || entry.Name() == ".vintage_cache.json" {
+			return err
+		}
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		relative, _ := filepath.Rel(root, path)
+		tree[relative] = string(body)
+		return nil
+	})
+	assert.NilError(t, err)
+	return tree
+}
@@ -0,0 +1,188 @@
+package vintage_test
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	liberrors "github.com/bbfh-dev/lib-errors"
+	liblog "github.com/bbfh-dev/lib-log"
+	"github.com/bbfh-dev/vintage/devkit"
+	"github.com/bbfh-dev/vintage/devkit/vfs"
+	"gotest.tools/assert"
+)
+
+const TEST_PACK_MCMETA = `{"meta": {"name": "test", "minecraft": "1.21.11", "version": "1.0.0"}}`
+
+// Builds a project made of [files] in memory and returns what is built
+func buildProject(t *testing.T, files map[string]string) (fs.FS, error) {
+	fsys := fstest.MapFS{"pack.mcmeta": {Data: []byte(TEST_PACK_MCMETA)}}
+	for name, body := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(body)}
+	}
+
+	liblog.Output = t.Output()
+	sink := vfs.NewMemorySink()
+	builder := devkit.NewBuilder(devkit.Options{Sink: sink, Force: true})
+	if err := builder.BuildFS(t.Context(), fsys); err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+// Builds a project made of [files] in memory and returns the contents of the data pack function "test:main"
+func buildFunction(t *testing.T, files map[string]string) (string, error) {
+	sink, err := buildProject(t, files)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := fs.ReadFile(sink, "data_pack/data/test/function/main.mcfunction")
+	assert.NilError(t, err)
+	return string(body), nil
+}
+
+func inlineManifest(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = `"` + arg + `"`
+	}
+	return `{"type": "inline", "arguments": [` + strings.Join(quoted, ", ") + `]}`
+}
+
+func TestRecursiveInlineTemplates(t *testing.T) {
+	body, err := buildFunction(t, map[string]string{
+		"templates/quote/manifest.json":      inlineManifest("text"),
+		"templates/quote/snippet.mcfunction": `say "%[text]"`,
+		"templates/twice/manifest.json":      inlineManifest("text"),
+		"templates/twice/snippet.mcfunction": "#~>quote %[text]\n#~>quote %[text]",
+		"data/test/function/main.mcfunction": "#~>twice hi",
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, body, "say \"hi\"\nsay \"hi\"")
+}
+
+func TestInlineTemplateCycle(t *testing.T) {
+	_, err := buildFunction(t, map[string]string{
+		"templates/a/manifest.json":          inlineManifest(),
+		"templates/a/snippet.mcfunction":     "#~>b",
+		"templates/b/manifest.json":          inlineManifest(),
+		"templates/b/snippet.mcfunction":     "#~>a",
+		"data/test/function/main.mcfunction": "say 1\n#~>a",
+	})
+
+	var detailed *liberrors.DetailedError
+	assert.Assert(t, errors.As(err, &detailed))
+	assert.Assert(t, strings.HasSuffix(detailed.Details, "a → b → a"), detailed.Details)
+}
+
+func TestTypedInlineTemplateArguments(t *testing.T) {
+	files := map[string]string{
+		"templates/give/manifest.json": `{"type": "inline", "arguments": [
+			{"name": "count", "type": "int", "min": 1},
+			{"name": "item", "type": "json"}
+		]}`,
+		"templates/give/snippet.mcfunction":  "give @s %[item.id] %[count]",
+		"data/test/function/main.mcfunction": "say 1\n#~>give 2 {\"id\":\"stone\"}",
+	}
+	body, err := buildFunction(t, files)
+	assert.NilError(t, err)
+	assert.Equal(t, body, "say 1\ngive @s stone 2")
+
+	files["data/test/function/main.mcfunction"] = "say 1\n#~>give 0 {\"id\":\"stone\"}"
+	_, err = buildFunction(t, files)
+
+	var detailed *liberrors.DetailedError
+	assert.Assert(t, errors.As(err, &detailed))
+	assert.Assert(t, strings.HasSuffix(detailed.Details, `argument "count" must be at least 1, but got "0"`), detailed.Details)
+	context, ok := detailed.Context.(liberrors.FileContext)
+	assert.Assert(t, ok)
+	assert.Equal(t, context.Buffer.Highlighted, `#~>give 0 {"id":"stone"}`)
+}
+
+func TestCallVariables(t *testing.T) {
+	body, err := buildFunction(t, map[string]string{
+		"templates/loop/manifest.json":       inlineManifest("name"),
+		"templates/loop/snippet.mcfunction":  "function ./_%[name]_%[call.counter]\n\t%[...]",
+		"templates/where/manifest.json":      inlineManifest(),
+		"templates/where/snippet.mcfunction": "say %[call.resource] %[call.namespace] %[call.counter]",
+		"data/test/function/main.mcfunction": "#~>loop a\n\tsay 1\n#~>loop a\n\tsay 2\n#~>where",
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, body, "function test:main/_a_1\nfunction test:main/_a_2\nsay test:main test 3")
+
+	body, err = buildFunction(t, map[string]string{
+		"templates/ids/manifest.json":        inlineManifest(),
+		"templates/ids/snippet.mcfunction":   "#~>id\n#~>id",
+		"templates/id/manifest.json":         inlineManifest(),
+		"templates/id/snippet.mcfunction":    "scoreboard players set #%[call.id] x 0",
+		"data/test/function/main.mcfunction": "#~>ids",
+	})
+	assert.NilError(t, err)
+	lines := strings.Split(body, "\n")
+	assert.Equal(t, len(lines), 2)
+	assert.Assert(t, lines[0] != lines[1], body)
+}
+
+func TestInlineTemplateSlots(t *testing.T) {
+	files := map[string]string{
+		"templates/try/manifest.json": `{"type": "inline", "slots": ["body", "finally"]}`,
+		"templates/try/snippet.mcfunction": strings.Join([]string{
+			"function ./_try",
+			"\t%[...body]",
+			"function ./_finally",
+			"\t%[...finally]",
+			"function ./_again",
+			"\t%[...]",
+		}, "\n"),
+		"data/test/function/main.mcfunction": strings.Join([]string{
+			"#~>try",
+			"\tsay 1",
+			"#~>> finally",
+			"\tsay 2",
+			"say 3",
+		}, "\n"),
+	}
+	sink, err := buildProject(t, files)
+	assert.NilError(t, err)
+	for name, expect := range map[string]string{
+		"main":          "function test:main/_try\nfunction test:main/_finally\nfunction test:main/_again\nsay 3",
+		"main/_try":     "say 1",
+		"main/_finally": "say 2",
+		"main/_again":   "say 1",
+	} {
+		body, err := fs.ReadFile(sink, "data_pack/data/test/function/"+name+".mcfunction")
+		assert.NilError(t, err, name)
+		assert.Equal(t, string(body), expect, name)
+	}
+
+	for main, expect := range map[string]string{
+		"say 1\n#~>> finally":                              "slot marker must follow the body",
+		"#~>try\n\tsay 1\n#~>> else\n\tsay 2":              `template "try" has no slot "else"`,
+		"#~>try\n#~>> finally\n\tsay 1\n#~>> finally\n\tx": `slot "finally" is given more than once`,
+	} {
+		files["data/test/function/main.mcfunction"] = main
+		_, err := buildFunction(t, files)
+		var detailed *liberrors.DetailedError
+		assert.Assert(t, errors.As(err, &detailed), main)
+		assert.Assert(t, strings.Contains(detailed.Details, expect), detailed.Details)
+	}
+
+	files["templates/try/snippet.mcfunction"] = "%[...catch]"
+	files["data/test/function/main.mcfunction"] = "say 1"
+	_, err = buildFunction(t, files)
+	assert.ErrorContains(t, err, `"%[...catch]" is not declared`)
+}
+
+// Calls without arguments can be written with a space, which must not be mistaken for a slot marker
+func TestInlineTemplateCallWithSpace(t *testing.T) {
+	body, err := buildFunction(t, map[string]string{
+		"templates/log/manifest.json":        `{"type": "inline"}`,
+		"templates/log/snippet.mcfunction":   "say logged",
+		"data/test/function/main.mcfunction": "#~> log",
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, body, "say logged")
+}